@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// BackfillOpts ...
+type BackfillOpts struct {
+	// Checkpoint is a path of the file progress is saved to after every topic.
+	Checkpoint string
+	// Overwrite fetches topics that are already in the backend again.
+	Overwrite bool
+	// Retries is a number of additional attempts made after failed request.
+	Retries int
+	// Backoff is a delay before first retry, it doubles with every next attempt up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// BackfillCheckpoint describes the last topic processed by backfill.
+type BackfillCheckpoint struct {
+	ForumID   int       `json:"forumId"`
+	PageID    int       `json:"pageId"`
+	TopicID   int       `json:"topicId"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Backfill walks every page of every forum and stores all topics found there in the backend.
+// Topics are stored as already expired, so server started on top of the same backend
+// keeps them in the archive instead of refreshing all of them.
+type Backfill struct {
+	retrier
+	client     Client
+	backend    TopicBackend
+	checkpoint string
+	overwrite  bool
+}
+
+// NewBackfill ...
+func NewBackfill(client Client, backend TopicBackend, options BackfillOpts) *Backfill {
+	return &Backfill{
+		retrier: retrier{
+			retries:    options.Retries,
+			backoff:    options.Backoff,
+			maxBackoff: options.MaxBackoff,
+		},
+		client:     client,
+		backend:    backend,
+		checkpoint: options.Checkpoint,
+		overwrite:  options.Overwrite,
+	}
+}
+
+// Run walks forums in order of their ids, resuming from the checkpoint if there is one.
+// Topic that cannot be fetched even after retries is logged and skipped, failed listing stops the run,
+// so it can be resumed later. Checkpoint is removed once every forum is done.
+func (b *Backfill) Run(ctx context.Context) error {
+	cp, err := b.loadCheckpoint()
+	if err != nil {
+		return err
+	}
+	if cp != nil {
+		log.Printf("backfill - resuming from forum %d, page %d, topic %d", cp.ForumID, cp.PageID, cp.TopicID)
+	}
+
+	var forums []*Forum
+
+	err = b.retry(ctx, func() (err error) {
+		forums, err = b.client.FetchForums(ctx)
+		return
+	})
+	if err != nil {
+		return err
+	}
+
+	forumIDs := make([]int, 0, len(forums))
+	for _, forum := range forums {
+		forumIDs = append(forumIDs, forum.ID)
+	}
+	sort.Ints(forumIDs)
+
+	for _, forumID := range forumIDs {
+		if cp != nil && forumID < cp.ForumID {
+			continue
+		}
+
+		var pages int
+
+		err := b.retry(ctx, func() (err error) {
+			pages, err = b.client.FetchForumPages(ctx, forumID)
+			return
+		})
+		if err != nil {
+			return fmt.Errorf("forum %d: %s", forumID, err.Error())
+		}
+
+		pageID := 0
+		if cp != nil && forumID == cp.ForumID {
+			pageID = cp.PageID
+		}
+
+		for ; pageID < pages; pageID++ {
+			var topics []*Topic
+
+			err := b.retry(ctx, func() (err error) {
+				topics, err = b.client.FetchTopicsForForum(ctx, forumID, pageID)
+				return
+			})
+			if err != nil {
+				return fmt.Errorf("forum %d page %d: %s", forumID, pageID, err.Error())
+			}
+
+			if cp != nil && forumID == cp.ForumID && pageID == cp.PageID {
+				topics = topicsAfter(topics, cp.TopicID)
+			}
+
+			for _, listing := range topics {
+				if err := b.store(ctx, listing); err != nil {
+					return err
+				}
+
+				cp = &BackfillCheckpoint{ForumID: forumID, PageID: pageID, TopicID: listing.ID, UpdatedAt: time.Now()}
+				if err := b.saveCheckpoint(cp); err != nil {
+					return err
+				}
+			}
+
+			log.Printf("backfill - forum %d, page %d/%d done", forumID, pageID+1, pages)
+		}
+	}
+
+	if err := os.Remove(b.checkpoint); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// store fetches and stores single topic, together with its listing metadata.
+// Only cancellation and backend failures are returned.
+func (b *Backfill) store(ctx context.Context, listing *Topic) error {
+	topicID := listing.ID
+
+	if !b.overwrite {
+		topic, _, err := b.backend.GetTopic(topicID)
+		if err != nil {
+			return err
+		}
+		if topic != nil {
+			return nil
+		}
+	}
+
+	var topic *Topic
+
+	err := b.retry(ctx, func() (err error) {
+		topic, err = b.client.FetchTopic(ctx, topicID)
+		return
+	})
+	switch {
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case err != nil:
+		log.Printf("backfill - topic %d skipped: %s", topicID, err.Error())
+		return nil
+	}
+
+	now := time.Now()
+	topic.LastRefreshedAt = &now
+	topic.applyListing(listing)
+
+	return b.backend.PutTopic(topic, time.Time{})
+}
+
+func (b *Backfill) loadCheckpoint() (*BackfillCheckpoint, error) {
+	data, err := ioutil.ReadFile(b.checkpoint)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cp BackfillCheckpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("malformed checkpoint %s: %s", b.checkpoint, err.Error())
+	}
+
+	return &cp, nil
+}
+
+// saveCheckpoint writes checkpoint to temporary file first, so interrupted write never leaves it corrupted.
+func (b *Backfill) saveCheckpoint(cp *BackfillCheckpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	tmp := b.checkpoint + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, b.checkpoint)
+}
+
+// topicsAfter returns topics listed after the one with given id. If it is not listed anymore, all of them are returned.
+func topicsAfter(topics []*Topic, topicID int) []*Topic {
+	for i, topic := range topics {
+		if topic.ID == topicID {
+			return topics[i+1:]
+		}
+	}
+
+	return topics
+}
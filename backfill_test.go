@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestBackfill_Run(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netwars-api")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	checkpoint := filepath.Join(dir, "checkpoint")
+	if !assert.NoError(t, ioutil.WriteFile(checkpoint, []byte(`{"forumId":12,"pageId":0,"topicId":3}`), 0600)) {
+		return
+	}
+
+	client := &ClientMock{}
+	client.On("FetchForums").Return([]*Forum{{ID: 12}, {ID: 10}}, nil)
+	client.On("FetchForumPages", 10).Return(1, nil)
+	client.On("FetchForumPages", 12).Return(2, nil)
+	client.On("FetchTopicsForForum", 10, 0).Return([]*Topic{{ID: 1}, {ID: 2}}, nil)
+	client.On("FetchTopicsForForum", 12, 0).Return([]*Topic{{ID: 3}, {ID: 5}}, nil)
+	client.On("FetchTopicsForForum", 12, 1).Return([]*Topic{{ID: 4}}, nil)
+	for _, id := range []int{1, 3, 4, 5} {
+		client.On("FetchTopic", id).Return(&Topic{ID: id}, nil).Once()
+	}
+	client.On("FetchTopic", 2).Return(nil, errors.New("not found")).Once()
+
+	backend := NewMemoryBackend()
+	backfill := NewBackfill(client, backend, BackfillOpts{Checkpoint: checkpoint})
+
+	// resumed run starts after the topic saved in the checkpoint
+	if !assert.NoError(t, backfill.Run(context.Background())) {
+		return
+	}
+	assertStoredTopics(t, backend, 4, 5)
+	_, err = os.Stat(checkpoint)
+	assert.True(t, os.IsNotExist(err), "checkpoint should be removed once backfill is done")
+
+	// full run skips stored topics and topics that cannot be fetched
+	if !assert.NoError(t, backfill.Run(context.Background())) {
+		return
+	}
+	assertStoredTopics(t, backend, 1, 3, 4, 5)
+
+	topic, storedAt, err := backend.GetTopic(1)
+	if assert.NoError(t, err) && assert.NotNil(t, topic) {
+		assert.True(t, storedAt.IsZero(), "backfilled topics should be stored as expired")
+		assert.NotNil(t, topic.LastRefreshedAt)
+	}
+	client.AssertExpectations(t)
+}
+
+func assertStoredTopics(t *testing.T, backend TopicBackend, expected ...int) {
+	ids := make(map[int]bool)
+	assert.NoError(t, backend.Topics(func(topic *Topic, _ time.Time) error {
+		ids[topic.ID] = true
+		return nil
+	}))
+
+	assert.Len(t, ids, len(expected))
+	for _, id := range expected {
+		assert.True(t, ids[id], "topic %d should be stored", id)
+	}
+}
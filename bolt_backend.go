@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltTopicsBucket = []byte("topics")
+	boltMetaBucket   = []byte("meta")
+	boltBackfilled   = []byte("backfilled")
+)
+
+// BoltBackend is a TopicBackend that keeps topics in a single BoltDB file.
+type BoltBackend struct {
+	db *bolt.DB
+}
+
+// NewBoltBackend opens, or creates if it does not exist, database under given path.
+func NewBoltBackend(path string) (*BoltBackend, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(boltTopicsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(boltMetaBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltBackend{db: db}, nil
+}
+
+// PutTopic implements TopicBackend.
+func (bb *BoltBackend) PutTopic(topic *Topic, storedAt time.Time) error {
+	value, err := json.Marshal(newStoredTopic(topic, storedAt))
+	if err != nil {
+		return err
+	}
+
+	return bb.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltTopicsBucket).Put(boltKey(topic.ID), value)
+	})
+}
+
+// TouchTopic implements TopicBackend.
+func (bb *BoltBackend) TouchTopic(id int, accessedAt time.Time) error {
+	return bb.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltTopicsBucket)
+
+		value := bucket.Get(boltKey(id))
+		if value == nil {
+			return nil
+		}
+
+		var st storedTopic
+		if err := json.Unmarshal(value, &st); err != nil {
+			return err
+		}
+		st.AccessedAt = accessedAt
+
+		value, err := json.Marshal(&st)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(boltKey(id), value)
+	})
+}
+
+// DeleteTopic implements TopicBackend.
+func (bb *BoltBackend) DeleteTopic(id int) error {
+	return bb.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltTopicsBucket).Delete(boltKey(id))
+	})
+}
+
+// GetTopic implements TopicBackend.
+func (bb *BoltBackend) GetTopic(id int) (topic *Topic, usedAt time.Time, err error) {
+	err = bb.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(boltTopicsBucket).Get(boltKey(id))
+		if value == nil {
+			return nil
+		}
+
+		var st storedTopic
+		if err := json.Unmarshal(value, &st); err != nil {
+			return err
+		}
+
+		topic, usedAt = st.topic(), st.usedAt()
+
+		return nil
+	})
+
+	return
+}
+
+// Topics implements TopicBackend.
+func (bb *BoltBackend) Topics(fn func(*Topic, time.Time) error) error {
+	return bb.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltTopicsBucket).ForEach(func(_, value []byte) error {
+			var st storedTopic
+			if err := json.Unmarshal(value, &st); err != nil {
+				return err
+			}
+
+			return fn(st.topic(), st.usedAt())
+		})
+	})
+}
+
+// MarkBackfilled records that the file holds backfilled topics. They are stored as already expired,
+// so they would be removed by server that runs without archive.
+func (bb *BoltBackend) MarkBackfilled() error {
+	return bb.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltMetaBucket).Put(boltBackfilled, []byte{1})
+	})
+}
+
+// Backfilled reports whether the file was ever written by backfill.
+func (bb *BoltBackend) Backfilled() (backfilled bool, err error) {
+	err = bb.db.View(func(tx *bolt.Tx) error {
+		backfilled = tx.Bucket(boltMetaBucket).Get(boltBackfilled) != nil
+		return nil
+	})
+
+	return
+}
+
+// Close implements TopicBackend.
+func (bb *BoltBackend) Close() error {
+	return bb.db.Close()
+}
+
+// boltKey encodes id in big endian, so topics are iterated in order of their ids.
+func boltKey(id int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id))
+
+	return key
+}
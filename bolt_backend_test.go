@@ -0,0 +1,107 @@
+package main
+
+import (
+	"crypto/sha1"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/netwars/api/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoltBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netwars-api")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	backend, err := NewBoltBackend(filepath.Join(dir, "topics.db"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer backend.Close()
+
+	now := time.Now()
+	posts := []*Post{
+		{Serial: 1, TopicID: 1, CreatedAt: &now, CreatedBy: "alice", Content: "Jak grać przeciwko zergom?"},
+		{Serial: 2, TopicID: 1, CreatedAt: &now, CreatedBy: "bob", Content: "Nie wiem."},
+	}
+	edited := *posts[1]
+	edited.HTML = "Nie wiem!"
+	posts[1].revisions = []*PostRevision{newPostRevision(&edited, 1, now), newPostRevision(posts[1], 2, now)}
+	topic := &Topic{ID: 1, ForumID: 12, Title: "Zergi", Pages: 2, UpdatedAt: &now, Posts: posts, pages: []*topicPage{
+		{etag: `"1"`, hash: sha1.Sum([]byte("1")), posts: posts[:1]},
+		{lastModified: now.Format(time.RFC1123), hash: sha1.Sum([]byte("2")), posts: posts[1:]},
+	}}
+
+	if !assert.NoError(t, backend.PutTopic(topic, now)) || !assert.NoError(t, backend.PutTopic(&Topic{ID: 2, ForumID: 12, Title: "Protosi"}, now)) {
+		return
+	}
+
+	// topic stored long ago, it should not be restored
+	err = backend.PutTopic(&Topic{ID: 3, ForumID: 12, Title: "Terranie"}, now.Add(-48*time.Hour))
+	if !assert.NoError(t, err) || !assert.NoError(t, backend.DeleteTopic(2)) {
+		return
+	}
+
+	// topic stored long ago, but read recently, it should be restored
+	err = backend.PutTopic(&Topic{ID: 4, ForumID: 13, Title: "Rasy", UpdatedAt: &now}, now.Add(-48*time.Hour))
+	if !assert.NoError(t, err) || !assert.NoError(t, backend.TouchTopic(4, now.Add(-time.Hour))) {
+		return
+	}
+
+	client := &ClientMock{}
+	store, err := NewTopicStore(client, cache.NewCache[int](cache.CacheOpts[*Topic]{
+		Expiration: 24 * time.Hour,
+		Interval:   1000000 * time.Hour,
+	}), NewCrawler(client, CrawlerOpts{}), NewForumStore(client, ForumStoreOpts{}), TopicStoreOpts{Backend: backend})
+	if !assert.NoError(t, err) {
+		return
+	}
+	go logErrorChannel("topic-storage", store.Err())
+
+	_, ok := store.Peek(4)
+	assert.True(t, ok, "recently read topic should be restored")
+
+	topics, err := store.ListByForum(12, 0, 10)
+	if !assert.NoError(t, err) || !assert.Len(t, topics, 1) {
+		return
+	}
+
+	restored := topics[0]
+	assert.Equal(t, "Zergi", restored.Title)
+	assert.Equal(t, 2, restored.Pages)
+	if assert.Len(t, restored.Posts, 2) {
+		assert.Equal(t, "bob", restored.Posts[1].CreatedBy)
+		assert.Len(t, restored.Posts[0].Revisions(), 1)
+		if assert.Len(t, restored.Posts[1].Revisions(), 2) {
+			assert.Equal(t, "Nie wiem!", restored.Posts[1].Revisions()[0].HTML)
+		}
+	}
+	if assert.Len(t, restored.pages, 2) {
+		assert.Equal(t, topic.pages[0].etag, restored.pages[0].etag)
+		assert.Equal(t, topic.pages[1].lastModified, restored.pages[1].lastModified)
+		assert.Equal(t, topic.pages[1].hash, restored.pages[1].hash)
+		if assert.Len(t, restored.pages[1].posts, 1) {
+			assert.Equal(t, int64(2), restored.pages[1].posts[0].Serial)
+		}
+	}
+
+	var ids []int
+	assert.NoError(t, backend.Topics(func(topic *Topic, _ time.Time) error {
+		ids = append(ids, topic.ID)
+		return nil
+	}))
+	assert.Equal(t, []int{1, 4}, ids)
+
+	backfilled, err := backend.Backfilled()
+	if assert.NoError(t, err) && assert.False(t, backfilled) && assert.NoError(t, backend.MarkBackfilled()) {
+		backfilled, err = backend.Backfilled()
+		assert.NoError(t, err)
+		assert.True(t, backfilled)
+	}
+}
@@ -1,9 +1,12 @@
 package cache
 
 import (
+	"container/heap"
 	"errors"
 	"fmt"
+	"iter"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -12,110 +15,353 @@ var (
 	ErrNotFound = errors.New("store: not found")
 )
 
+// ExpiredError is sent through Err channel when entry expires and is removed from the cache.
+type ExpiredError[K comparable] struct {
+	Key K
+}
+
+// Error implements error interface.
+func (e *ExpiredError[K]) Error() string {
+	return fmt.Sprintf("Cache expired for ID: %v", e.Key)
+}
+
 // CacheOpts ...
-type CacheOpts struct {
+type CacheOpts[V any] struct {
 	Expiration time.Duration
 	Interval   time.Duration
+	// MaxEntries and MaxBytes bound the cache, entries selected by Eviction are removed once any of them is exceeded.
+	// Zero means no limit. MaxBytes takes effect only if Size is given.
+	MaxEntries int
+	MaxBytes   int64
+	// Size returns approximate number of bytes used by the value.
+	Size     func(V) int64
+	Eviction Eviction
 }
 
-// Cache ...
-type Cache struct {
+// Cache keeps entries in memory, notifies about each of them every interval and removes those that are not accessed.
+// Refreshes and expiration of all entries are handled by a single goroutine, that sleeps until the closest deadline.
+type Cache[K comparable, V any] struct {
 	sync.RWMutex
 	err          chan error
-	notification chan int
+	notification chan K
 	expiration   time.Duration
 	interval     time.Duration
-	rows         map[int]interface{}
-	retrieved    map[int]bool
-	tickers      map[int]*time.Ticker
-	timers       map[int]*time.Timer
-	cancel       map[int]chan struct{}
+	entries      map[K]*entry[K, V]
+	queue        entryQueue[K, V]
+	pinned       map[K]int
+	maxEntries   int
+	maxBytes     int64
+	size         func(V) int64
+	bytes        int64
+	policy       policy[K]
+	counters     counters
+	// evicted keeps keys that are waiting to be reported by the scheduler.
+	evicted []K
+	epoch   time.Time
+	wake    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// entry holds value together with its deadlines, expressed as time elapsed since the cache epoch.
+type entry[K comparable, V any] struct {
+	// expiresAt is extended by Get, that can be called concurrently under read lock, hence it is accessed atomically.
+	// It goes first to stay 64-bit aligned on 32-bit platforms.
+	expiresAt int64
+	hits      atomic.Uint64
+	refreshAt int64
+	// refreshedAt is the time entry was set or last reported through Notify channel.
+	refreshedAt int64
+	interval    int64
+	// setAt is the time value was stored, succeededAt the time of the last refresh reported as successful.
+	setAt       int64
+	succeededAt int64
+	failures    int
+	lastErr     error
+	key         K
+	value       V
+	size        int64
+	// due is the deadline entry is ordered by in the queue, the earlier of expiresAt and refreshAt.
+	due   int64
+	index int
 }
 
 // NewCache ...
-func NewCache(options CacheOpts) *Cache {
-	return &Cache{
-		rows:         make(map[int]interface{}),
-		tickers:      make(map[int]*time.Ticker),
-		timers:       make(map[int]*time.Timer),
-		cancel:       make(map[int]chan struct{}),
+func NewCache[K comparable, V any](options CacheOpts[V]) *Cache[K, V] {
+	c := &Cache[K, V]{
+		entries:      make(map[K]*entry[K, V]),
+		pinned:       make(map[K]int),
 		err:          make(chan error, 1),
-		notification: make(chan int),
+		notification: make(chan K),
 		expiration:   options.Expiration,
 		interval:     options.Interval,
+		maxEntries:   options.MaxEntries,
+		maxBytes:     options.MaxBytes,
+		size:         options.Size,
+		epoch:        time.Now(),
+		wake:         make(chan struct{}, 1),
+		done:         make(chan struct{}),
+		stopped:      make(chan struct{}),
+	}
+	if c.size == nil {
+		c.maxBytes = 0
 	}
+	if c.maxEntries > 0 || c.maxBytes > 0 {
+		c.policy = newPolicy[K](options.Eviction, options.MaxEntries)
+	}
+
+	go c.schedule()
+
+	return c
+}
+
+// Expiration returns default time after which entry that is not accessed is removed.
+func (c *Cache[K, V]) Expiration() time.Duration {
+	return c.expiration
 }
 
 // Notify ...
-func (c *Cache) Notify() <-chan int {
+func (c *Cache[K, V]) Notify() <-chan K {
 	return c.notification
 }
 
 // Set ...
-func (c *Cache) Set(key int, value interface{}) {
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.SetWithExpiration(key, value, c.expiration)
+}
+
+// SetWithExpiration works like Set, but entry expires after given duration, unless it is accessed before.
+// Every access extends its life by the default expiration.
+func (c *Cache[K, V]) SetWithExpiration(key K, value V, expiration time.Duration) {
 	c.Lock()
 	defer c.Unlock()
 
-	if _, exists := c.rows[key]; exists {
-		if cancel, exists := c.cancel[key]; exists {
-			close(cancel)
+	now := c.now()
+	e := &entry[K, V]{
+		key:         key,
+		value:       value,
+		expiresAt:   now + int64(expiration),
+		refreshedAt: now,
+		interval:    int64(c.interval),
+		setAt:       now,
+	}
+	if prev, exists := c.entries[key]; exists {
+		heap.Remove(&c.queue, prev.index)
+		c.bytes -= prev.size
+
+		// replaced value is still the same entry, its schedule and history are kept
+		e.interval = prev.interval
+		e.hits.Store(prev.hits.Load())
+		e.succeededAt = prev.succeededAt
+		e.failures = prev.failures
+		e.lastErr = prev.lastErr
+	}
+	e.refreshAt = now + e.interval
+	if c.size != nil {
+		e.size = c.size(value)
+	}
+	e.due = e.deadline()
+	c.entries[key] = e
+	c.bytes += e.size
+	heap.Push(&c.queue, e)
+
+	// scheduler has to recalculate its sleep only if the new entry is the closest one
+	if e.index == 0 {
+		c.signal()
+	}
+
+	if c.policy != nil {
+		c.policy.add(key)
+		c.evict()
+	}
+}
+
+// evict removes entries chosen by the policy until the cache fits its limits. Pinned entries are never evicted.
+// Evictions are reported by the scheduler, so Set never blocks on Err channel.
+func (c *Cache[K, V]) evict() {
+	n := len(c.evicted)
+
+	for (c.maxEntries > 0 && len(c.entries) > c.maxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		key, ok := c.policy.victim(c.isPinned)
+		if !ok {
+			break
 		}
+
 		c.delete(key)
+		c.evicted = append(c.evicted, key)
+		c.counters.evictions.Add(1)
+	}
+
+	if len(c.evicted) > n {
+		c.signal()
 	}
+}
 
-	c.rows[key] = value
-	c.tickers[key] = time.NewTicker(c.interval)
-	c.timers[key] = time.NewTimer(c.expiration)
-	c.cancel[key] = make(chan struct{})
+// SetInterval changes how often entry is reported through Notify channel. Replaced entry keeps its interval.
+// Next notification is sent interval after the previous one, or right away if that moment has already passed.
+func (c *Cache[K, V]) SetInterval(key K, interval time.Duration) {
+	c.Lock()
+	defer c.Unlock()
 
-	go c.schedule(key)
+	e, exists := c.entries[key]
+	if !exists {
+		return
+	}
+
+	e.interval = int64(interval)
+	e.refreshAt = e.refreshedAt + e.interval
+	e.due = e.deadline()
+	heap.Fix(&c.queue, e.index)
+
+	if e.index == 0 {
+		c.signal()
+	}
 }
 
-// Get ...
-func (c *Cache) Get(key int) interface{} {
-	if row, exists := c.rows[key]; exists {
-		if c.timers[key].Reset(c.expiration) {
-			return row
+// Update replaces value of existing entry with the one returned by fn, keeping its expiration and refresh schedule.
+// It reports whether entry exists. Fn is called with the cache locked.
+func (c *Cache[K, V]) Update(key K, fn func(V) V) bool {
+	c.Lock()
+	defer c.Unlock()
+
+	e, exists := c.entries[key]
+	if !exists {
+		return false
+	}
+
+	e.value = fn(e.value)
+	if c.size != nil {
+		c.bytes -= e.size
+		e.size = c.size(e.value)
+		c.bytes += e.size
+		if c.policy != nil {
+			c.evict()
 		}
 	}
 
-	return nil
+	return true
+}
+
+// isPinned is not thread safe!
+func (c *Cache[K, V]) isPinned(key K) bool {
+	return c.pinned[key] > 0
+}
+
+// Get returns value stored under given key and extends its expiration. It is not thread safe!
+func (c *Cache[K, V]) Get(key K) (value V, ok bool) {
+	e, exists := c.entries[key]
+	if !exists {
+		c.counters.misses.Add(1)
+		return value, false
+	}
+
+	// entry may have just expired, scheduler removes it unless it is pinned
+	now := c.now()
+	if atomic.LoadInt64(&e.expiresAt) <= now && c.pinned[key] == 0 {
+		c.counters.misses.Add(1)
+		return value, false
+	}
+	atomic.StoreInt64(&e.expiresAt, now+int64(c.expiration))
+	if c.policy != nil {
+		c.policy.access(key)
+	}
+	c.counters.hits.Add(1)
+	e.hits.Add(1)
+
+	return e.value, true
+}
+
+// Lookup returns value stored under given key like Peek does, without extending its expiration,
+// recording access or counting a hit. It is meant for the owner of the cache, e.g. to sort entries. It is not thread safe!
+func (c *Cache[K, V]) Lookup(key K) (value V, ok bool) {
+	if e, exists := c.entries[key]; exists {
+		return e.value, true
+	}
+
+	return value, false
 }
 
-// SafeGet ...
-func (c *Cache) SafeGet(key int) interface{} {
+// SafeGet works like Get, but it is thread safe.
+func (c *Cache[K, V]) SafeGet(key K) (V, bool) {
 	c.RLock()
 	defer c.RUnlock()
 
 	return c.Get(key)
 }
 
-// Delete ...
-func (c *Cache) Delete(id int) {
+// Peek returns value stored under given key without extending its expiration. It is thread safe.
+func (c *Cache[K, V]) Peek(key K) (V, bool) {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.Lookup(key)
+}
+
+// All iterates over snapshot of the cache, taken when iteration starts. Expiration of entries is not extended.
+// Cache can be modified during the iteration.
+func (c *Cache[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		c.RLock()
+		entries := make([]*entry[K, V], 0, len(c.entries))
+		for _, e := range c.entries {
+			entries = append(entries, e)
+		}
+		c.RUnlock()
+
+		for _, e := range entries {
+			if !yield(e.key, e.value) {
+				return
+			}
+		}
+	}
+}
+
+// Pin prevents entry from expiring, it is still refreshed periodically. Every call has to be followed by Unpin.
+func (c *Cache[K, V]) Pin(key K) {
 	c.Lock()
 	defer c.Unlock()
 
-	if cancel, exists := c.cancel[id]; exists {
-		close(cancel)
+	c.pinned[key]++
+}
+
+// Unpin reverts single Pin call. Entry expires normally once all pins are removed.
+func (c *Cache[K, V]) Unpin(key K) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.pinned[key] <= 1 {
+		delete(c.pinned, key)
+		return
 	}
 
+	c.pinned[key]--
+}
+
+// Delete ...
+func (c *Cache[K, V]) Delete(id K) {
+	c.Lock()
+	defer c.Unlock()
+
 	c.delete(id)
 }
 
 // Len ...
-func (c *Cache) Len() int {
+func (c *Cache[K, V]) Len() int {
 	c.RLock()
 	defer c.RUnlock()
 
-	return len(c.rows)
+	return len(c.entries)
 }
 
-// Terminate ...
-func (c *Cache) Terminate() {
+// Terminate stops the scheduler, removes all entries and closes both channels.
+func (c *Cache[K, V]) Terminate() {
+	close(c.done)
+	<-c.stopped
+
 	c.Lock()
 	defer c.Unlock()
 
-	for id := range c.rows {
+	for id := range c.entries {
 		c.delete(id)
 	}
 
@@ -123,50 +369,175 @@ func (c *Cache) Terminate() {
 	close(c.err)
 }
 
-func (c *Cache) delete(key int) {
-	if timer, exists := c.timers[key]; exists {
-		timer.Stop()
-	}
+func (c *Cache[K, V]) delete(key K) {
+	if e, exists := c.entries[key]; exists {
+		heap.Remove(&c.queue, e.index)
+		delete(c.entries, key)
+		c.bytes -= e.size
 
-	if ticker, exists := c.tickers[key]; exists {
-		ticker.Stop()
+		if c.policy != nil {
+			c.policy.remove(key)
+		}
 	}
-
-	delete(c.rows, key)
-	delete(c.cancel, key)
-	delete(c.tickers, key)
-	delete(c.timers, key)
 }
 
 // Err ...
-func (c *Cache) Err() <-chan error {
+func (c *Cache[K, V]) Err() <-chan error {
 	return c.err
 }
 
-func (c *Cache) schedule(id int) {
-	c.RLock()
-	ticker, exists := c.tickers[id]
-	timer, exists2 := c.timers[id]
-	cancel, exists3 := c.cancel[id]
-	if !exists || !exists2 || !exists3 {
-		c.RUnlock()
-		return
+// now returns monotonic time elapsed since the cache was created.
+func (c *Cache[K, V]) now() int64 {
+	return int64(time.Since(c.epoch))
+}
+
+// signal wakes up the scheduler, if it is not already about to wake up.
+func (c *Cache[K, V]) signal() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
 	}
-	c.RUnlock()
+}
+
+// schedule sleeps until the closest deadline, then sends notifications and expiration errors for all entries that are due.
+// It also reports entries evicted in the meantime.
+// Channels are written without holding the lock, so slow consumer delays the scheduler, but never blocks the cache.
+func (c *Cache[K, V]) schedule() {
+	defer close(c.stopped)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
-			c.notification <- id
 		case <-timer.C:
-			c.Lock()
-			c.delete(id)
-			c.Unlock()
-
-			c.err <- fmt.Errorf("Cache expired for ID: %d", id)
-			return
-		case <-cancel:
+		case <-c.wake:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		case <-c.done:
 			return
 		}
+
+		refresh, errs, next := c.due()
+
+		for _, key := range refresh {
+			c.RLock()
+			_, exists := c.entries[key]
+			c.RUnlock()
+			if !exists {
+				continue
+			}
+
+			select {
+			case c.notification <- key:
+				c.counters.refreshes.Add(1)
+			case <-c.done:
+				return
+			}
+		}
+		for _, err := range errs {
+			select {
+			case c.err <- err:
+			case <-c.done:
+				return
+			}
+		}
+
+		// deadline could be reached while channels were written, in that case timer fires immediately
+		timer.Reset(time.Duration(next - c.now()))
+	}
+}
+
+// due collects entries whose deadlines passed. Expired entries are removed, unless pinned, and the rest is rescheduled.
+// It returns also errors for expired and evicted entries and the closest deadline that remains in the queue.
+func (c *Cache[K, V]) due() (refresh []K, errs []error, next int64) {
+	c.Lock()
+	defer c.Unlock()
+
+	for _, key := range c.evicted {
+		errs = append(errs, &EvictedError[K]{Key: key})
+	}
+	c.evicted = nil
+
+	now := c.now()
+	for len(c.queue) > 0 && c.queue[0].due <= now {
+		e := c.queue[0]
+
+		if atomic.LoadInt64(&e.expiresAt) <= now {
+			if c.pinned[e.key] == 0 {
+				c.delete(e.key)
+				errs = append(errs, &ExpiredError[K]{Key: e.key})
+				c.counters.expirations.Add(1)
+				continue
+			}
+			atomic.StoreInt64(&e.expiresAt, now+int64(c.expiration))
+		}
+		// missed refreshes are not caught up, the same way time.Ticker drops ticks
+		if e.refreshAt <= now {
+			refresh = append(refresh, e.key)
+			e.refreshedAt = now
+			e.refreshAt = now + e.interval
+		}
+
+		e.due = e.deadline()
+		heap.Fix(&c.queue, 0)
+	}
+
+	// nothing to wait for, scheduler is woken up by the next Set
+	next = now + int64(time.Hour)
+	if len(c.queue) > 0 && c.queue[0].due < next {
+		next = c.queue[0].due
+	}
+
+	return refresh, errs, next
+}
+
+// deadline returns the earlier of entry deadlines. Extended expiration is picked up once the previous one is reached.
+func (e *entry[K, V]) deadline() int64 {
+	if expiresAt := atomic.LoadInt64(&e.expiresAt); expiresAt < e.refreshAt {
+		return expiresAt
 	}
+
+	return e.refreshAt
+}
+
+// entryQueue is a min-heap of entries ordered by their closest deadline.
+type entryQueue[K comparable, V any] []*entry[K, V]
+
+// Len implements heap.Interface.
+func (eq entryQueue[K, V]) Len() int {
+	return len(eq)
+}
+
+// Less implements heap.Interface.
+func (eq entryQueue[K, V]) Less(i, j int) bool {
+	return eq[i].due < eq[j].due
+}
+
+// Swap implements heap.Interface.
+func (eq entryQueue[K, V]) Swap(i, j int) {
+	eq[i], eq[j] = eq[j], eq[i]
+	eq[i].index = i
+	eq[j].index = j
+}
+
+// Push implements heap.Interface.
+func (eq *entryQueue[K, V]) Push(x interface{}) {
+	e := x.(*entry[K, V])
+	e.index = len(*eq)
+	*eq = append(*eq, e)
+}
+
+// Pop implements heap.Interface.
+func (eq *entryQueue[K, V]) Pop() interface{} {
+	old := *eq
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*eq = old[:len(old)-1]
+
+	return e
 }
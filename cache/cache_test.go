@@ -1,7 +1,9 @@
 package cache_test
 
 import (
+	"errors"
 	"fmt"
+	"runtime"
 	"sync"
 	"testing"
 	"time"
@@ -11,25 +13,26 @@ import (
 )
 
 const (
-	benchmarkValue = "value"
+	benchmarkValue   = "value"
+	benchmarkEntries = 100000
 )
 
 var (
-	benchmarkResult interface{}
+	benchmarkResult string
 )
 
 func TestCache(t *testing.T) {
 	n := int(500)
-	data := make([]interface{}, 0, n)
+	data := make([]string, 0, n)
 	for i := int(0); i < n; i++ {
 		data = append(data, fmt.Sprintf("forum%d", i))
 	}
 
-	options := cache.CacheOpts{
+	options := cache.CacheOpts[string]{
 		Expiration: 100000 * time.Second,
 		Interval:   1 * time.Second,
 	}
-	ca := cache.NewCache(options)
+	ca := cache.NewCache[int](options)
 
 	go func() {
 		for {
@@ -57,32 +60,244 @@ func TestCache(t *testing.T) {
 	wg := sync.WaitGroup{}
 	for i, expected := range data {
 		wg.Add(1)
-		go func(i int, expected interface{}) {
+		go func(i int, expected string) {
 			defer wg.Done()
-			assert.Equal(t, expected, ca.Get(i))
+			value, ok := ca.SafeGet(i)
+			assert.True(t, ok)
+			assert.Equal(t, expected, value)
 		}(i, expected)
 	}
 	wg.Wait()
 
 	ca.Terminate()
 
-	for i, expected := range data {
+	for i := range data {
 		wg.Add(1)
-		go func(i int, expected interface{}) {
+		go func(i int) {
 			defer wg.Done()
-			assert.Nil(t, ca.Get(i))
-		}(i, expected)
+			_, ok := ca.SafeGet(i)
+			assert.False(t, ok)
+		}(i)
 	}
 	wg.Wait()
 }
 
+func TestCache_Pin(t *testing.T) {
+	ca := cache.NewCache[int](cache.CacheOpts[string]{
+		Expiration: 50 * time.Millisecond,
+		Interval:   100000 * time.Second,
+	})
+	defer ca.Terminate()
+
+	ca.Set(1, "forum1")
+	ca.Pin(1)
+
+	time.Sleep(150 * time.Millisecond)
+	value, _ := ca.Get(1)
+	assert.Equal(t, "forum1", value)
+
+	ca.Unpin(1)
+
+	select {
+	case err := <-ca.Err():
+		if expired, ok := err.(*cache.ExpiredError[int]); assert.True(t, ok) {
+			assert.Equal(t, 1, expired.Key)
+		}
+	case <-time.After(time.Second):
+		assert.Fail(t, "entry should expire once unpinned")
+	}
+	_, ok := ca.Get(1)
+	assert.False(t, ok)
+}
+
+func TestCache_Eviction(t *testing.T) {
+	for name, eviction := range map[string]cache.Eviction{
+		"lru":     cache.EvictLRU,
+		"lfu":     cache.EvictLFU,
+		"tinylfu": cache.EvictTinyLFU,
+	} {
+		ca := cache.NewCache[int](cache.CacheOpts[string]{
+			Expiration: 100000 * time.Second,
+			Interval:   100000 * time.Second,
+			MaxEntries: 3,
+			Eviction:   eviction,
+		})
+
+		ca.Set(1, "forum1")
+		ca.Set(2, "forum2")
+		ca.Set(3, "forum3")
+		ca.Pin(3)
+		ca.Get(1)
+		ca.Get(1)
+		ca.Set(4, "forum4")
+
+		select {
+		case err := <-ca.Err():
+			if evicted, ok := err.(*cache.EvictedError[int]); assert.True(t, ok, name) {
+				assert.Equal(t, 2, evicted.Key, name)
+			}
+		case <-time.After(time.Second):
+			assert.Fail(t, "entry should be evicted", name)
+		}
+		assert.Equal(t, 3, ca.Len(), name)
+		_, ok := ca.Get(2)
+		assert.False(t, ok, name)
+		value, _ := ca.Get(1)
+		assert.Equal(t, "forum1", value, name)
+
+		ca.Terminate()
+	}
+}
+
+func TestCache_EvictionBytes(t *testing.T) {
+	ca := cache.NewCache[int](cache.CacheOpts[string]{
+		Expiration: 100000 * time.Second,
+		Interval:   100000 * time.Second,
+		MaxBytes:   10,
+		Size: func(value string) int64 {
+			return int64(len(value))
+		},
+	})
+	defer ca.Terminate()
+
+	ca.Set(1, "four")
+	ca.Set(2, "four")
+	assert.Equal(t, 2, ca.Len())
+
+	// replacing entry accounts for its new size
+	ca.Set(1, "eight!!!")
+
+	select {
+	case err := <-ca.Err():
+		if evicted, ok := err.(*cache.EvictedError[int]); assert.True(t, ok) {
+			assert.Equal(t, 2, evicted.Key)
+		}
+	case <-time.After(time.Second):
+		assert.Fail(t, "entry should be evicted")
+	}
+	value, _ := ca.Get(1)
+	assert.Equal(t, "eight!!!", value)
+	assert.Equal(t, 1, ca.Len())
+}
+
+func TestCache_All(t *testing.T) {
+	type page struct {
+		forumID, pageID int
+	}
+
+	ca := cache.NewCache[page](cache.CacheOpts[[]int]{
+		Expiration: 100000 * time.Second,
+		Interval:   100000 * time.Second,
+		MaxEntries: 2,
+		Eviction:   cache.EvictTinyLFU,
+	})
+	defer ca.Terminate()
+
+	ca.Set(page{1, 0}, []int{10, 11})
+	ca.Set(page{1, 1}, []int{12})
+	ca.Set(page{2, 0}, []int{20})
+
+	got := make(map[page][]int)
+	for key, value := range ca.All() {
+		got[key] = value
+	}
+	assert.Len(t, got, 2)
+	assert.Equal(t, []int{20}, got[page{2, 0}])
+}
+
+func TestCache_SetInterval(t *testing.T) {
+	ca := cache.NewCache[int](cache.CacheOpts[string]{
+		Expiration: 100000 * time.Second,
+		Interval:   100000 * time.Second,
+	})
+	defer ca.Terminate()
+
+	ca.Set(1, "forum1")
+	ca.Set(2, "forum2")
+	ca.SetInterval(2, 20*time.Millisecond)
+
+	// replaced entry keeps its interval
+	ca.Set(2, "forum2")
+
+	for i := 0; i < 2; i++ {
+		select {
+		case key := <-ca.Notify():
+			assert.Equal(t, 2, key)
+		case <-time.After(time.Second):
+			assert.Fail(t, "entry should be refreshed with its own interval")
+			return
+		}
+	}
+}
+
+func TestCache_Stats(t *testing.T) {
+	ca := cache.NewCache[int](cache.CacheOpts[string]{
+		Expiration: 100000 * time.Second,
+		Interval:   100000 * time.Second,
+		Size:       func(v string) int64 { return int64(len(v)) },
+	})
+	defer ca.Terminate()
+
+	ca.Set(1, "forum1")
+	ca.SetWithExpiration(2, "forum2", 20*time.Millisecond)
+	ca.Pin(1)
+	ca.Get(1)
+	ca.Get(1)
+	ca.Get(3)
+	ca.Lookup(1)
+
+	ca.RecordRefresh(1, errors.New("timeout"))
+	ca.RecordRefresh(1, errors.New("bad gateway"))
+	entry, ok := ca.Inspect(1)
+	if assert.True(t, ok) {
+		assert.Equal(t, uint64(2), entry.Hits)
+		assert.True(t, entry.Pinned)
+		assert.Equal(t, int64(6), entry.Size)
+		assert.Equal(t, 2, entry.Failures)
+		assert.EqualError(t, entry.LastError, "bad gateway")
+		assert.True(t, entry.RefreshedAt.IsZero())
+		assert.Equal(t, entry.SetAt.Add(100000*time.Second), entry.NextRefreshAt)
+	}
+
+	// replaced value keeps history of the entry, until refresh succeeds
+	ca.Set(1, "forum1")
+	entry, _ = ca.Inspect(1)
+	assert.Equal(t, 2, entry.Failures)
+	ca.RecordRefresh(1, nil)
+	entry, _ = ca.Inspect(1)
+	assert.Zero(t, entry.Failures)
+	assert.NoError(t, entry.LastError)
+	assert.False(t, entry.RefreshedAt.IsZero())
+
+	select {
+	case <-ca.Err():
+	case <-time.After(time.Second):
+		assert.Fail(t, "entry should expire")
+	}
+	_, ok = ca.Inspect(2)
+	assert.False(t, ok)
+
+	stats := ca.Stats()
+	assert.Equal(t, 1, stats.Entries)
+	assert.Equal(t, 1, stats.Pinned)
+	assert.Equal(t, int64(6), stats.Bytes)
+	assert.Equal(t, uint64(2), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, uint64(1), stats.RefreshSuccesses)
+	assert.Equal(t, uint64(2), stats.RefreshFailures)
+	assert.Equal(t, uint64(1), stats.Expirations)
+	if assert.Len(t, stats.Ages, len(cache.AgeBuckets)+1) {
+		assert.Equal(t, 1, stats.Ages[0])
+	}
+}
+
 func BenchmarkCacheSet(b *testing.B) {
 	b.Log("bench")
-	options := cache.CacheOpts{
+	options := cache.CacheOpts[string]{
 		Expiration: 100000 * time.Second,
 		Interval:   100000 * time.Second,
 	}
-	ca := cache.NewCache(options)
+	ca := cache.NewCache[int](options)
 
 	go func() {
 		for {
@@ -101,13 +316,13 @@ func BenchmarkCacheSet(b *testing.B) {
 }
 
 func BenchmarkCacheGet(b *testing.B) {
-	var r interface{}
+	var r string
 
-	options := cache.CacheOpts{
+	options := cache.CacheOpts[string]{
 		Expiration: 100000 * time.Second,
 		Interval:   100000 * time.Second,
 	}
-	ca := cache.NewCache(options)
+	ca := cache.NewCache[int](options)
 
 	go func() {
 		for {
@@ -125,8 +340,68 @@ func BenchmarkCacheGet(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		r = ca.Get(i)
+		r, _ = ca.Get(i)
 	}
 
 	benchmarkResult = r
 }
+
+// BenchmarkCacheSet_100k measures time and memory needed to fill the cache with 100k entries.
+func BenchmarkCacheSet_100k(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		ca := cache.NewCache[int](cache.CacheOpts[string]{
+			Expiration: 100000 * time.Second,
+			Interval:   100000 * time.Second,
+		})
+
+		before := heapInUse()
+		for key := 0; key < benchmarkEntries; key++ {
+			ca.Set(key, benchmarkValue)
+		}
+
+		b.StopTimer()
+		b.ReportMetric(float64(heapInUse()-before)/benchmarkEntries, "heap-B/entry")
+		b.ReportMetric(float64(runtime.NumGoroutine()), "goroutines")
+		ca.Terminate()
+		b.StartTimer()
+	}
+}
+
+// BenchmarkCacheGet_100k measures access time while the cache holds 100k entries that are refreshed often.
+func BenchmarkCacheGet_100k(b *testing.B) {
+	var r string
+
+	ca := cache.NewCache[int](cache.CacheOpts[string]{
+		Expiration: 100000 * time.Second,
+		Interval:   100 * time.Millisecond,
+	})
+	defer ca.Terminate()
+
+	go func() {
+		for range ca.Notify() {
+		}
+	}()
+
+	for key := 0; key < benchmarkEntries; key++ {
+		ca.Set(key, benchmarkValue)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r, _ = ca.SafeGet(i % benchmarkEntries)
+	}
+
+	benchmarkResult = r
+}
+
+func heapInUse() uint64 {
+	var stats runtime.MemStats
+
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+
+	return stats.HeapInuse
+}
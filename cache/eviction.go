@@ -0,0 +1,243 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+	"fmt"
+	"sync"
+)
+
+// Eviction selects entries that are removed once the cache exceeds its capacity.
+type Eviction int
+
+const (
+	// EvictLRU removes the least recently used entry.
+	EvictLRU Eviction = iota
+	// EvictLFU removes the least frequently used entry, ties are broken by recency.
+	EvictLFU
+	// EvictTinyLFU admits entries to the main space only if they are used more often than entries they would replace.
+	// New entries are kept in a small LRU window, so bursts are not rejected right away. See W-TinyLFU.
+	EvictTinyLFU
+)
+
+// EvictedError is sent through Err channel when entry is removed because the cache exceeded its capacity.
+type EvictedError[K comparable] struct {
+	Key K
+}
+
+// Error implements error interface.
+func (e *EvictedError[K]) Error() string {
+	return fmt.Sprintf("Cache evicted ID: %v", e.Key)
+}
+
+// policy keeps track of entry usage. It is called with the cache lock held, but access can be recorded
+// under read lock only, so implementations have to guard their state on their own.
+type policy[K comparable] interface {
+	add(key K)
+	access(key K)
+	remove(key K)
+	// victim returns key to evict, skipping keys that cannot be evicted. It returns false if there is none.
+	victim(skip func(K) bool) (K, bool)
+}
+
+func newPolicy[K comparable](eviction Eviction, capacity int) policy[K] {
+	switch eviction {
+	case EvictLFU:
+		return newLFU[K]()
+	case EvictTinyLFU:
+		return newTinyLFU[K](capacity)
+	default:
+		return newLRU[K]()
+	}
+}
+
+// lru orders keys from the most recently used one.
+type lru[K comparable] struct {
+	sync.Mutex
+	order  *list.List
+	values map[K]*list.Element
+}
+
+func newLRU[K comparable]() *lru[K] {
+	return &lru[K]{
+		order:  list.New(),
+		values: make(map[K]*list.Element),
+	}
+}
+
+func (l *lru[K]) add(key K) {
+	l.Lock()
+	defer l.Unlock()
+
+	if el, ok := l.values[key]; ok {
+		l.order.MoveToFront(el)
+		return
+	}
+	l.values[key] = l.order.PushFront(key)
+}
+
+func (l *lru[K]) access(key K) {
+	l.Lock()
+	defer l.Unlock()
+
+	if el, ok := l.values[key]; ok {
+		l.order.MoveToFront(el)
+	}
+}
+
+func (l *lru[K]) remove(key K) {
+	l.Lock()
+	defer l.Unlock()
+
+	if el, ok := l.values[key]; ok {
+		l.order.Remove(el)
+		delete(l.values, key)
+	}
+}
+
+func (l *lru[K]) victim(skip func(K) bool) (K, bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	return lastOf(l.order, skip)
+}
+
+// lastOf returns the last key of the list that is not skipped.
+func lastOf[K comparable](order *list.List, skip func(K) bool) (key K, ok bool) {
+	for el := order.Back(); el != nil; el = el.Prev() {
+		if key := el.Value.(K); !skip(key) {
+			return key, true
+		}
+	}
+
+	return key, false
+}
+
+// lfu keeps keys in a min-heap ordered by number of accesses and then by time of the last one.
+type lfu[K comparable] struct {
+	sync.Mutex
+	clock  uint64
+	queue  lfuQueue[K]
+	values map[K]*lfuEntry[K]
+}
+
+type lfuEntry[K comparable] struct {
+	key   K
+	count uint64
+	tick  uint64
+	index int
+}
+
+func newLFU[K comparable]() *lfu[K] {
+	return &lfu[K]{
+		values: make(map[K]*lfuEntry[K]),
+	}
+}
+
+func (l *lfu[K]) add(key K) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.clock++
+	if e, ok := l.values[key]; ok {
+		e.count++
+		e.tick = l.clock
+		heap.Fix(&l.queue, e.index)
+		return
+	}
+
+	e := &lfuEntry[K]{key: key, count: 1, tick: l.clock}
+	l.values[key] = e
+	heap.Push(&l.queue, e)
+}
+
+func (l *lfu[K]) access(key K) {
+	l.Lock()
+	defer l.Unlock()
+
+	if e, ok := l.values[key]; ok {
+		l.clock++
+		e.count++
+		e.tick = l.clock
+		heap.Fix(&l.queue, e.index)
+	}
+}
+
+func (l *lfu[K]) remove(key K) {
+	l.Lock()
+	defer l.Unlock()
+
+	if e, ok := l.values[key]; ok {
+		heap.Remove(&l.queue, e.index)
+		delete(l.values, key)
+	}
+}
+
+func (l *lfu[K]) victim(skip func(K) bool) (key K, ok bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	if len(l.queue) == 0 {
+		return key, false
+	}
+	if !skip(l.queue[0].key) {
+		return l.queue[0].key, true
+	}
+
+	// root is pinned, which is rare, so a linear scan is good enough
+	var found *lfuEntry[K]
+	for _, e := range l.queue {
+		if !skip(e.key) && (found == nil || l.queue.less(e, found)) {
+			found = e
+		}
+	}
+	if found == nil {
+		return key, false
+	}
+
+	return found.key, true
+}
+
+type lfuQueue[K comparable] []*lfuEntry[K]
+
+func (lq lfuQueue[K]) less(a, b *lfuEntry[K]) bool {
+	if a.count == b.count {
+		return a.tick < b.tick
+	}
+
+	return a.count < b.count
+}
+
+// Len implements heap.Interface.
+func (lq lfuQueue[K]) Len() int {
+	return len(lq)
+}
+
+// Less implements heap.Interface.
+func (lq lfuQueue[K]) Less(i, j int) bool {
+	return lq.less(lq[i], lq[j])
+}
+
+// Swap implements heap.Interface.
+func (lq lfuQueue[K]) Swap(i, j int) {
+	lq[i], lq[j] = lq[j], lq[i]
+	lq[i].index = i
+	lq[j].index = j
+}
+
+// Push implements heap.Interface.
+func (lq *lfuQueue[K]) Push(x interface{}) {
+	e := x.(*lfuEntry[K])
+	e.index = len(*lq)
+	*lq = append(*lq, e)
+}
+
+// Pop implements heap.Interface.
+func (lq *lfuQueue[K]) Pop() interface{} {
+	old := *lq
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*lq = old[:len(old)-1]
+
+	return e
+}
@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// AgeBuckets are upper bounds of the age histogram returned by Stats.
+var AgeBuckets = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute, time.Hour, 6 * time.Hour, 24 * time.Hour}
+
+// Stats describes how the cache performs since it was created.
+type Stats struct {
+	Entries int
+	Pinned  int
+	Bytes   int64
+	// Hits and Misses count Get calls, including those made through SafeGet.
+	Hits   uint64
+	Misses uint64
+	// Refreshes counts notifications sent through Notify channel. Their outcome is reported by the owner of the cache
+	// with RecordRefresh and counted as RefreshSuccesses and RefreshFailures.
+	Refreshes        uint64
+	RefreshSuccesses uint64
+	RefreshFailures  uint64
+	Expirations      uint64
+	Evictions        uint64
+	// Ages counts entries by time since their value was set. Ages[i] holds entries younger than AgeBuckets[i],
+	// the last element holds those older than every bucket.
+	Ages []int
+}
+
+// EntryStats describes single entry of the cache.
+type EntryStats[K comparable] struct {
+	Key    K
+	Size   int64
+	Pinned bool
+	Hits   uint64
+	// SetAt is the time value was stored for the last time.
+	SetAt time.Time
+	// RefreshedAt is the time of the last successful refresh, zero if there was none.
+	RefreshedAt   time.Time
+	NextRefreshAt time.Time
+	ExpiresAt     time.Time
+	Interval      time.Duration
+	// Failures counts refreshes that failed since the last successful one, LastError is the most recent of them.
+	Failures  int
+	LastError error
+}
+
+// counters are updated under read lock as well, so all of them are accessed atomically.
+type counters struct {
+	hits             atomic.Uint64
+	misses           atomic.Uint64
+	refreshes        atomic.Uint64
+	refreshSuccesses atomic.Uint64
+	refreshFailures  atomic.Uint64
+	expirations      atomic.Uint64
+	evictions        atomic.Uint64
+}
+
+// RecordRefresh reports outcome of refresh requested through Notify channel. Nil error means that entry is up to date,
+// whether it was replaced by Set or found unchanged. Refreshes of keys that are not cached anymore are ignored.
+func (c *Cache[K, V]) RecordRefresh(key K, err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	e, exists := c.entries[key]
+	if !exists {
+		return
+	}
+
+	if err != nil {
+		e.failures++
+		e.lastErr = err
+		c.counters.refreshFailures.Add(1)
+		return
+	}
+
+	e.succeededAt = c.now()
+	e.failures = 0
+	e.lastErr = nil
+	c.counters.refreshSuccesses.Add(1)
+}
+
+// Stats returns counters of the cache together with age distribution of its entries.
+func (c *Cache[K, V]) Stats() Stats {
+	c.RLock()
+	defer c.RUnlock()
+
+	stats := Stats{
+		Entries:          len(c.entries),
+		Bytes:            c.bytes,
+		Hits:             c.counters.hits.Load(),
+		Misses:           c.counters.misses.Load(),
+		Refreshes:        c.counters.refreshes.Load(),
+		RefreshSuccesses: c.counters.refreshSuccesses.Load(),
+		RefreshFailures:  c.counters.refreshFailures.Load(),
+		Expirations:      c.counters.expirations.Load(),
+		Evictions:        c.counters.evictions.Load(),
+		Ages:             make([]int, len(AgeBuckets)+1),
+	}
+
+	now := c.now()
+	for key, e := range c.entries {
+		if c.pinned[key] > 0 {
+			stats.Pinned++
+		}
+
+		age := time.Duration(now - e.setAt)
+
+		i := 0
+		for i < len(AgeBuckets) && age >= AgeBuckets[i] {
+			i++
+		}
+		stats.Ages[i]++
+	}
+
+	return stats
+}
+
+// Inspect returns details of the entry stored under given key, without extending its expiration.
+func (c *Cache[K, V]) Inspect(key K) (EntryStats[K], bool) {
+	c.RLock()
+	defer c.RUnlock()
+
+	e, exists := c.entries[key]
+	if !exists {
+		return EntryStats[K]{Key: key}, false
+	}
+
+	stats := EntryStats[K]{
+		Key:           key,
+		Size:          e.size,
+		Pinned:        c.pinned[key] > 0,
+		Hits:          e.hits.Load(),
+		SetAt:         c.time(e.setAt),
+		NextRefreshAt: c.time(e.refreshAt),
+		ExpiresAt:     c.time(atomic.LoadInt64(&e.expiresAt)),
+		Interval:      time.Duration(e.interval),
+		Failures:      e.failures,
+		LastError:     e.lastErr,
+	}
+	if e.succeededAt != 0 {
+		stats.RefreshedAt = c.time(e.succeededAt)
+	}
+
+	return stats, true
+}
+
+// time converts time elapsed since the cache epoch to wall clock time.
+func (c *Cache[K, V]) time(elapsed int64) time.Time {
+	return c.epoch.Add(time.Duration(elapsed))
+}
@@ -0,0 +1,249 @@
+package cache
+
+import (
+	"container/list"
+	"hash/maphash"
+	"sync"
+)
+
+const (
+	// tinyLFUWindow is the part of the cache that admits every new entry.
+	tinyLFUWindow = 0.01
+	// tinyLFUProtected is the part of the main space kept for entries accessed at least twice.
+	tinyLFUProtected = 0.8
+	// tinyLFUSketchWidth is used when number of entries is not bounded, only their size.
+	tinyLFUSketchWidth = 1 << 16
+	sketchDepth        = 4
+	sketchMaxCount     = 15
+)
+
+const (
+	segmentWindow = iota
+	segmentProbation
+	segmentProtected
+)
+
+// tinyLFU implements W-TinyLFU: new entries land in a small LRU window, from which they move to the probation segment
+// of the main space. Once the cache is full, the newest entry of probation competes with the oldest one,
+// and the one with lower estimated frequency is evicted. Entries accessed in probation are promoted to protected segment.
+type tinyLFU[K comparable] struct {
+	sync.Mutex
+	capacity  int
+	sketch    *sketch[K]
+	window    *list.List
+	probation *list.List
+	protected *list.List
+	values    map[K]*tinyLFUEntry
+}
+
+type tinyLFUEntry struct {
+	segment int
+	element *list.Element
+}
+
+func newTinyLFU[K comparable](capacity int) *tinyLFU[K] {
+	width := tinyLFUSketchWidth
+	if capacity > 0 {
+		width = capacity
+	}
+
+	return &tinyLFU[K]{
+		capacity:  capacity,
+		sketch:    newSketch[K](width),
+		window:    list.New(),
+		probation: list.New(),
+		protected: list.New(),
+		values:    make(map[K]*tinyLFUEntry),
+	}
+}
+
+func (t *tinyLFU[K]) add(key K) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.sketch.increment(key)
+	if _, ok := t.values[key]; ok {
+		t.touch(key)
+		return
+	}
+
+	t.values[key] = &tinyLFUEntry{segment: segmentWindow, element: t.window.PushFront(key)}
+
+	// window overflow becomes a candidate for the main space
+	for t.window.Len() > t.limit(tinyLFUWindow) {
+		t.move(t.window.Back().Value.(K), segmentProbation)
+	}
+}
+
+func (t *tinyLFU[K]) access(key K) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.sketch.increment(key)
+	t.touch(key)
+}
+
+func (t *tinyLFU[K]) remove(key K) {
+	t.Lock()
+	defer t.Unlock()
+
+	if e, ok := t.values[key]; ok {
+		t.segment(e.segment).Remove(e.element)
+		delete(t.values, key)
+	}
+}
+
+func (t *tinyLFU[K]) victim(skip func(K) bool) (K, bool) {
+	t.Lock()
+	defer t.Unlock()
+
+	victim, ok := lastOf(t.probation, skip)
+	if !ok {
+		if victim, ok = lastOf(t.protected, skip); !ok {
+			return lastOf(t.window, skip)
+		}
+		return victim, true
+	}
+
+	var candidate K
+	for el := t.probation.Front(); el != nil; el = el.Next() {
+		if candidate = el.Value.(K); !skip(candidate) {
+			break
+		}
+	}
+	if candidate == victim {
+		return victim, true
+	}
+
+	// candidate is admitted only if it is used more often than entry it would replace
+	if t.sketch.estimate(candidate) > t.sketch.estimate(victim) {
+		return victim, true
+	}
+
+	return candidate, true
+}
+
+// touch moves accessed entry to the front of its segment, entries accessed in probation are promoted.
+func (t *tinyLFU[K]) touch(key K) {
+	e, ok := t.values[key]
+	if !ok {
+		return
+	}
+
+	switch e.segment {
+	case segmentProbation:
+		t.move(key, segmentProtected)
+
+		// protected overflow goes back to probation
+		for t.protected.Len() > int(float64(t.probation.Len()+t.protected.Len())*tinyLFUProtected)+1 {
+			t.move(t.protected.Back().Value.(K), segmentProbation)
+		}
+	default:
+		t.segment(e.segment).MoveToFront(e.element)
+	}
+}
+
+// move puts entry in front of given segment.
+func (t *tinyLFU[K]) move(key K, segment int) {
+	e := t.values[key]
+	t.segment(e.segment).Remove(e.element)
+	e.segment = segment
+	e.element = t.segment(segment).PushFront(key)
+}
+
+func (t *tinyLFU[K]) segment(segment int) *list.List {
+	switch segment {
+	case segmentProbation:
+		return t.probation
+	case segmentProtected:
+		return t.protected
+	default:
+		return t.window
+	}
+}
+
+// limit returns given part of the capacity, or of the current number of entries if capacity is not bounded.
+func (t *tinyLFU[K]) limit(part float64) int {
+	total := t.capacity
+	if total == 0 {
+		total = len(t.values)
+	}
+
+	if limit := int(float64(total) * part); limit > 1 {
+		return limit
+	}
+
+	return 1
+}
+
+// sketch is a count-min sketch that estimates how often keys were used.
+// Counters saturate at 15 and are halved periodically, so old popularity fades away.
+type sketch[K comparable] struct {
+	seed      maphash.Seed
+	rows      [sketchDepth][]uint8
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+func newSketch[K comparable](width int) *sketch[K] {
+	size := 1
+	for size < width {
+		size <<= 1
+	}
+
+	s := &sketch[K]{
+		seed:    maphash.MakeSeed(),
+		mask:    uint64(size - 1),
+		resetAt: 10 * size,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, size)
+	}
+
+	return s
+}
+
+func (s *sketch[K]) increment(key K) {
+	h := maphash.Comparable(s.seed, key)
+	for i := range s.rows {
+		if j := s.index(h, i); s.rows[i][j] < sketchMaxCount {
+			s.rows[i][j]++
+		}
+	}
+
+	if s.additions++; s.additions >= s.resetAt {
+		s.reset()
+	}
+}
+
+func (s *sketch[K]) estimate(key K) uint8 {
+	h := maphash.Comparable(s.seed, key)
+	min := uint8(sketchMaxCount)
+	for i := range s.rows {
+		if count := s.rows[i][s.index(h, i)]; count < min {
+			min = count
+		}
+	}
+
+	return min
+}
+
+func (s *sketch[K]) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] /= 2
+		}
+	}
+	s.additions /= 2
+}
+
+// index derives different position for every row from hash of the key, using splitmix64 finalizer.
+func (s *sketch[K]) index(h uint64, row int) uint64 {
+	h += uint64(row+1) * 0x9e3779b97f4a7c15
+	h = (h ^ (h >> 30)) * 0xbf58476d1ce4e5b9
+	h = (h ^ (h >> 27)) * 0x94d049bb133111eb
+	h ^= h >> 31
+
+	return h & s.mask
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/piotrkowalczuk/rest"
+	"golang.org/x/net/context"
+)
+
+// CacheEntryGetResponse ...
+type CacheEntryGetResponse struct {
+	TopicID     int        `json:"topicId"`
+	Title       string     `json:"title"`
+	Size        int64      `json:"size"`
+	Pinned      bool       `json:"pinned"`
+	Hits        uint64     `json:"hits"`
+	SetAt       time.Time  `json:"setAt"`
+	RefreshedAt *time.Time `json:"refreshedAt,omitempty"`
+	// Interval is the time between refreshes, in seconds.
+	Interval      float64   `json:"interval"`
+	NextRefreshAt time.Time `json:"nextRefreshAt"`
+	ExpiresAt     time.Time `json:"expiresAt"`
+	Failures      int       `json:"failures"`
+	LastError     string    `json:"lastError,omitempty"`
+}
+
+// CacheEntryGetEndpoint explains state of cached topic: when it was refreshed, when it will be refreshed next
+// and why refreshes fail. It does not extend expiration of the topic.
+func CacheEntryGetEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(TopicGetRequest)
+	if !ok {
+		return nil, rest.InternalServerError(errBadCast, internalServerErrorMessage, 0)
+	}
+
+	topicStorage, err := TopicStorageFromContext(ctx)
+	if err != nil {
+		return nil, rest.InternalServerError(err, internalServerErrorMessage, 0)
+	}
+
+	topic, ok := topicStorage.Peek(req.TopicID)
+	if !ok {
+		return nil, &rest.Error{Message: "topic not cached", HTTPCode: http.StatusNotFound}
+	}
+	stats, ok := topicStorage.Inspect(req.TopicID)
+	if !ok {
+		return nil, &rest.Error{Message: "topic not cached", HTTPCode: http.StatusNotFound}
+	}
+
+	res := &CacheEntryGetResponse{
+		TopicID:       req.TopicID,
+		Title:         topic.Title,
+		Size:          stats.Size,
+		Pinned:        stats.Pinned,
+		Hits:          stats.Hits,
+		SetAt:         stats.SetAt,
+		Interval:      stats.Interval.Seconds(),
+		NextRefreshAt: stats.NextRefreshAt,
+		ExpiresAt:     stats.ExpiresAt,
+		Failures:      stats.Failures,
+	}
+	if !stats.RefreshedAt.IsZero() {
+		res.RefreshedAt = &stats.RefreshedAt
+	}
+	if stats.LastError != nil {
+		res.LastError = stats.LastError.Error()
+	}
+
+	return res, nil
+}
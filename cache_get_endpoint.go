@@ -0,0 +1,83 @@
+package main
+
+import (
+	"github.com/netwars/api/cache"
+	"github.com/piotrkowalczuk/rest"
+	"golang.org/x/net/context"
+)
+
+// CacheGetResponse ...
+type CacheGetResponse struct {
+	Topics CacheStats `json:"topics"`
+	Users  CacheStats `json:"users"`
+}
+
+// CacheStats ...
+type CacheStats struct {
+	Entries          int     `json:"entries"`
+	Pinned           int     `json:"pinned"`
+	Bytes            int64   `json:"bytes"`
+	Hits             uint64  `json:"hits"`
+	Misses           uint64  `json:"misses"`
+	HitRatio         float64 `json:"hitRatio"`
+	Refreshes        uint64  `json:"refreshes"`
+	RefreshSuccesses uint64  `json:"refreshSuccesses"`
+	RefreshFailures  uint64  `json:"refreshFailures"`
+	Expirations      uint64  `json:"expirations"`
+	Evictions        uint64  `json:"evictions"`
+	// Ages is a histogram of time since entries were stored, from the youngest ones.
+	Ages []CacheAgeBucket `json:"ages"`
+}
+
+// CacheAgeBucket counts entries younger than MaxAge, in seconds. The last bucket has no upper bound.
+type CacheAgeBucket struct {
+	MaxAge  float64 `json:"maxAge,omitempty"`
+	Entries int     `json:"entries"`
+}
+
+// CacheGetEndpoint returns statistics of topic and user caches.
+func CacheGetEndpoint(ctx context.Context, _ interface{}) (interface{}, error) {
+	topicStorage, err := TopicStorageFromContext(ctx)
+	if err != nil {
+		return nil, rest.InternalServerError(err, internalServerErrorMessage, 0)
+	}
+
+	userStorage, err := UserStorageFromContext(ctx)
+	if err != nil {
+		return nil, rest.InternalServerError(err, internalServerErrorMessage, 0)
+	}
+
+	return &CacheGetResponse{
+		Topics: newCacheStats(topicStorage.Cache.Stats()),
+		Users:  newCacheStats(userStorage.Cache.Stats()),
+	}, nil
+}
+
+func newCacheStats(stats cache.Stats) CacheStats {
+	cs := CacheStats{
+		Entries:          stats.Entries,
+		Pinned:           stats.Pinned,
+		Bytes:            stats.Bytes,
+		Hits:             stats.Hits,
+		Misses:           stats.Misses,
+		Refreshes:        stats.Refreshes,
+		RefreshSuccesses: stats.RefreshSuccesses,
+		RefreshFailures:  stats.RefreshFailures,
+		Expirations:      stats.Expirations,
+		Evictions:        stats.Evictions,
+		Ages:             make([]CacheAgeBucket, 0, len(stats.Ages)),
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		cs.HitRatio = float64(stats.Hits) / float64(total)
+	}
+
+	for i, entries := range stats.Ages {
+		bucket := CacheAgeBucket{Entries: entries}
+		if i < len(cache.AgeBuckets) {
+			bucket.MaxAge = cache.AgeBuckets[i].Seconds()
+		}
+		cs.Ages = append(cs.Ages, bucket)
+	}
+
+	return cs
+}
@@ -1,130 +1,404 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha1"
 	"errors"
+	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
 )
 
+const (
+	// topicPageConcurrency is the maximum number of topic pages downloaded at once.
+	topicPageConcurrency = 4
+	// clientDefaultUserAgent is sent with every request if ClientOpts does not specify one.
+	clientDefaultUserAgent = "netwars-api (+https://github.com/netwars/api)"
+)
+
+var (
+	// ErrNotModified is returned by RefreshTopic if topic did not change since previous fetch.
+	ErrNotModified = errors.New("client: not modified")
+	// ErrTopicDeleted is returned by FetchTopic and RefreshTopic if topic does not exist anymore:
+	// server responds with 404 or 410, redirects somewhere else or the first page has no posts.
+	ErrTopicDeleted = errors.New("client: topic deleted")
+)
+
+// statusError is returned when server responds with unexpected status code.
+type statusError struct {
+	code int
+	url  string
+}
+
+// Error implements error interface.
+func (se *statusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d for %s", se.code, se.url)
+}
+
 // Client ...
 type Client interface {
-	FetchTopic(int) (*Topic, error)
-	FetchTopics(int, chan<- *Topic) error
+	FetchForums(context.Context) ([]*Forum, error)
+	FetchForumPages(context.Context, int) (int, error)
+	FetchTopicsForForum(context.Context, int, int) ([]*Topic, error)
+	FetchTopic(context.Context, int) (*Topic, error)
+	RefreshTopic(context.Context, *Topic) (*Topic, error)
+	FetchUser(context.Context, int) (*User, error)
+}
+
+// ClientOpts ...
+type ClientOpts struct {
+	// Throttle, if set, is consulted before every request.
+	Throttle *Throttle
+	// Transport is used to perform requests, if nil dedicated http.Transport is created.
+	Transport http.RoundTripper
+	// Timeout limits time of a single request, including reading the response body.
+	Timeout   time.Duration
+	UserAgent string
 }
 
 type client struct {
-	url *url.URL
+	url       *url.URL
+	http      *http.Client
+	throttle  *Throttle
+	userAgent string
 }
 
 // NewClient ...
-func NewClient(u *url.URL) Client {
+func NewClient(u *url.URL, options ClientOpts) Client {
+	if options.Transport == nil {
+		options.Transport = &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			MaxIdleConnsPerHost: topicPageConcurrency,
+			TLSHandshakeTimeout: 10 * time.Second,
+		}
+	}
+	if options.UserAgent == "" {
+		options.UserAgent = clientDefaultUserAgent
+	}
+
 	return &client{
 		url: u,
+		http: &http.Client{
+			Transport: options.Transport,
+			Timeout:   options.Timeout,
+		},
+		throttle:  options.Throttle,
+		userAgent: options.UserAgent,
 	}
 }
 
 // FetchDocument ...
-func (c *client) FetchDocument(url string) (*goquery.Document, error) {
-	resp, err := http.Get(url)
+func (c *client) FetchDocument(ctx context.Context, url string) (*goquery.Document, error) {
+	doc, _, err := c.fetchPage(ctx, url, nil)
+
+	return doc, err
+}
+
+// fetchPage downloads and parses document. If previous version of the page is given,
+// request is conditional and ErrNotModified is returned when server responds with 304 Not Modified
+// or when the body is identical to the one seen before. In that case posts of the previous version are carried over.
+func (c *client) fetchPage(ctx context.Context, url string, prev *topicPage) (*goquery.Document, *topicPage, error) {
+	if c.throttle != nil {
+		if err := c.throttle.Wait(ctx, c.url.Host); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	if prev != nil {
+		if prev.etag != "" {
+			req.Header.Set("If-None-Match", prev.etag)
+		}
+		if prev.lastModified != "" {
+			req.Header.Set("If-Modified-Since", prev.lastModified)
+		}
+	}
+
+	resp, err := ctxhttp.Do(ctx, c.http, req)
+	if err != nil {
+		return nil, nil, err
 	}
+	defer resp.Body.Close()
 
-	return goquery.NewDocumentFromResponse(resp)
+	if prev != nil && resp.StatusCode == http.StatusNotModified {
+		return nil, prev, ErrNotModified
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, &statusError{code: resp.StatusCode, url: url}
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	page := &topicPage{
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		hash:         sha1.Sum(body),
+	}
+	if prev != nil && prev.hash == page.hash {
+		page.posts = prev.posts
+
+		return nil, page, ErrNotModified
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	doc.Url = resp.Request.URL
+
+	return doc, page, nil
 }
 
-// FetchTopic ...
-func (c *client) FetchTopic(topicID int) (*Topic, error) {
-	doc, err := c.FetchDocument(c.url.String() + "/temat/" + strconv.FormatInt(int64(topicID), 10))
+// FetchForums scrapes the board index and returns all forums listed there.
+func (c *client) FetchForums(ctx context.Context) ([]*Forum, error) {
+	doc, err := c.FetchDocument(ctx, c.url.String()+"/forum")
 	if err != nil {
 		return nil, err
 	}
 
-	topic, err := NewTopicFromDocument(doc)
-	if err != nil {
+	return NewForumsFromDocument(doc)
+}
+
+// FetchTopic downloads every page of the topic and merges posts from all of them.
+func (c *client) FetchTopic(ctx context.Context, topicID int) (*Topic, error) {
+	return c.fetchTopic(ctx, topicID, nil)
+}
+
+// RefreshTopic downloads the topic again using validators remembered by previous version.
+// Only pages that changed are parsed. If none of them did, ErrNotModified is returned.
+func (c *client) RefreshTopic(ctx context.Context, prev *Topic) (*Topic, error) {
+	return c.fetchTopic(ctx, prev.ID, prev)
+}
+
+func (c *client) fetchTopic(ctx context.Context, topicID int, prev *Topic) (*Topic, error) {
+	var topic *Topic
+
+	topicURL := c.url.String() + "/temat/" + strconv.FormatInt(int64(topicID), 10)
+
+	doc, first, err := c.fetchPage(ctx, topicURL, prev.page(0))
+	modified := err == nil
+
+	if se, ok := err.(*statusError); ok && (se.code == http.StatusNotFound || se.code == http.StatusGone) {
+		return nil, ErrTopicDeleted
+	}
+
+	switch err {
+	case nil:
+		// removed topic redirects to the forum
+		if id, err := topicIDFromURL(doc.Url); err != nil || int(id) != topicID {
+			return nil, ErrTopicDeleted
+		}
+		if first.posts, err = NewPostsFromDocument(doc); err != nil {
+			return nil, err
+		}
+		// page that is served, but has no posts is rather broken than deleted, so it is retried
+		if len(first.posts) == 0 {
+			return nil, errors.New("missing posts in document")
+		}
+		if topic, err = NewTopicFromDocument(doc); err != nil {
+			return nil, err
+		}
+		if topic.Pages, err = pageCountFromDocument(doc); err != nil {
+			return nil, err
+		}
+	case ErrNotModified:
+		header := *prev
+		topic = &header
+	default:
 		return nil, err
 	}
 
-	topic.Posts, err = NewPostsFromDocument(doc)
+	others, othersModified, err := c.fetchTopicPages(ctx, topicURL, topic.Pages, prev)
 	if err != nil {
 		return nil, err
 	}
+	if !modified && !othersModified {
+		return nil, ErrNotModified
+	}
+
+	topic.pages = append([]*topicPage{first}, others...)
+
+	posts := make([][]*Post, 0, len(topic.pages))
+	for _, page := range topic.pages {
+		posts = append(posts, page.posts)
+	}
+	topic.Posts = resolveReplies(mergePosts(posts...))
+	topic.fillFromPosts()
 
 	return topic, nil
 }
 
-// FetchTopics ...
-func (c *client) FetchTopics(nbOfPages int, result chan<- *Topic) error {
-	ids := []int{ForumIDStarCraft, ForumIDStarCraft2, ForumIDOtherGames, ForumIDOffTopic}
+// fetchTopicPages downloads all pages of the topic except the first one.
+// It fails if any of them cannot be fetched or parsed, so the topic is never silently truncated.
+// First failure cancels downloads that are still in progress.
+// Returned flag reports whether any of the pages differs from previous version of the topic.
+func (c *client) fetchTopicPages(ctx context.Context, topicURL string, nbOfPages int, prev *Topic) ([]*topicPage, bool, error) {
+	if nbOfPages < 2 {
+		return nil, false, nil
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		once     sync.Once
+		firstErr error
+		modified bool
+	)
 
-	for _, id := range ids {
-		if err := c.FetchTopicsForForum(id, nbOfPages, result); err != nil {
-			return err
-		}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	fail := func(pageID int, err error) {
+		once.Do(func() {
+			firstErr = fmt.Errorf("topic page %d: %s", pageID, err.Error())
+			cancel()
+		})
 	}
 
-	return nil
-}
+	pages := make([]*topicPage, nbOfPages-1)
+	semaphore := make(chan struct{}, topicPageConcurrency)
 
-// FetchTopicsForForum ...
-func (c *client) FetchTopicsForForum(id, nbOfPages int, result chan<- *Topic) error {
-	forumURL := c.url.String() + "/forum/" + strconv.FormatInt(int64(id), 10)
+	for pageID := 1; pageID < nbOfPages; pageID++ {
+		wg.Add(1)
+		go func(pageID int) {
+			defer wg.Done()
 
-	doc, err := c.FetchDocument(forumURL)
-	if err != nil {
-		return err
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				fail(pageID, ctx.Err())
+				return
+			}
+
+			doc, page, err := c.fetchPage(ctx, topicURL+"/"+strconv.FormatInt(int64(pageID), 10), prev.page(pageID))
+			switch err {
+			case nil:
+				if page.posts, err = NewPostsFromDocument(doc); err != nil {
+					fail(pageID, err)
+					return
+				}
+
+				mu.Lock()
+				modified = true
+				mu.Unlock()
+			case ErrNotModified:
+			default:
+				fail(pageID, err)
+				return
+			}
+
+			pages[pageID-1] = page
+		}(pageID)
 	}
+	wg.Wait()
 
-	navi := doc.Find("ul.forum_navi a[href^='/forum/']")
+	if firstErr != nil {
+		return nil, false, firstErr
+	}
 
-	forumLink, _ := navi.Attr("href")
+	return pages, modified, nil
+}
+
+// FetchForumPages returns number of listing pages of the forum.
+func (c *client) FetchForumPages(ctx context.Context, forumID int) (int, error) {
+	doc, err := c.FetchDocument(ctx, c.forumURL(forumID))
+	if err != nil {
+		return 0, err
+	}
+
+	forumLink, _ := doc.Find("ul.forum_navi a[href^='/forum/']").Attr("href")
 	if forumLink == "" {
-		return errors.New("missing forum link")
+		return 0, errors.New("missing forum link")
 	}
 
 	lastPageLink := doc.Find("ul.pagination_list li:last-of-type a").Text()
 	if lastPageLink == "" {
-		return errors.New("missing last page link")
+		return 0, errors.New("missing last page link")
 	}
 
 	lastPageID, err := strconv.ParseInt(lastPageLink, 10, 32)
 	if err != nil {
-		return errors.New("malformed last page ID")
+		return 0, errors.New("malformed last page ID")
 	}
 
-	if int(lastPageID) < nbOfPages {
-		nbOfPages = int(lastPageID)
+	return int(lastPageID), nil
+}
+
+// FetchTopicsForForum returns topics listed on given page of the forum. They contain listing metadata, but no posts.
+func (c *client) FetchTopicsForForum(ctx context.Context, forumID, pageID int) ([]*Topic, error) {
+	doc, err := c.FetchDocument(ctx, c.forumURL(forumID)+"/"+strconv.FormatInt(int64(pageID), 10))
+	if err != nil {
+		return nil, err
 	}
-	for pageID := 0; pageID < nbOfPages; pageID++ {
-		doc, err := c.FetchDocument(forumURL + "/" + strconv.FormatInt(int64(pageID), 10))
-		if err != nil {
-			return err
-		}
 
-		topicRowsSelection := doc.Find("table td.topic a[href^='/temat/']")
+	return NewTopicsFromForumDocument(doc, forumID)
+}
 
-		for i := 0; i < topicRowsSelection.Length(); i++ {
-			href, exists := topicRowsSelection.Eq(i).Attr("href")
-			if !exists {
-				continue
-			}
+// FetchUser scrapes profile page of the user.
+func (c *client) FetchUser(ctx context.Context, userID int) (*User, error) {
+	doc, err := c.FetchDocument(ctx, c.url.String()+"/profil/"+strconv.FormatInt(int64(userID), 10))
+	if err != nil {
+		return nil, err
+	}
 
-			topicID, err := strconv.ParseInt(href[7:], 10, 32)
-			if err != nil {
-				return err
-			}
+	return NewUserFromDocument(doc)
+}
+
+func (c *client) forumURL(forumID int) string {
+	return c.url.String() + "/forum/" + strconv.FormatInt(int64(forumID), 10)
+}
 
-			topic, err := c.FetchTopic(int(topicID))
-			if err != nil {
-				return err
+// pageCountFromDocument returns number of pages based on pagination list.
+// Document without pagination is considered to be a single page.
+func pageCountFromDocument(doc *goquery.Document) (int, error) {
+	lastPageLink := doc.Find("ul.pagination_list li:last-of-type a").Text()
+	if lastPageLink == "" {
+		return 1, nil
+	}
+
+	lastPageID, err := strconv.ParseInt(lastPageLink, 10, 32)
+	if err != nil {
+		return 0, errors.New("malformed last page ID")
+	}
+
+	return int(lastPageID), nil
+}
+
+// mergePosts joins posts from multiple pages into one slice ordered by serial.
+// Posts that appear on more than one page are included only once.
+func mergePosts(pages ...[]*Post) []*Post {
+	seen := make(map[int64]struct{})
+	merged := make(postsBySerial, 0)
+
+	for _, posts := range pages {
+		for _, post := range posts {
+			if _, ok := seen[post.Serial]; ok {
+				continue
 			}
 
-			result <- topic
+			seen[post.Serial] = struct{}{}
+			merged = append(merged, post)
 		}
 	}
 
-	return nil
+	sort.Sort(merged)
+
+	return merged
 }
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+const (
+	testTopicPageHead = `<html><head><title>Test topic - Netwars.pl</title></head><body>
+<ul class="forum_navi"><li><a href="/forum/12">StarCraft II</a></li></ul>`
+	testTopicPageFoot = `</body></html>`
+	testTopicPost     = `<div class="post" id="post_%d">
+<div class="posthead"><span class="numerek_posta"># %d.</span><div class="p2_data">2015-06-01 12:00:%02d</div></div>
+<div class="p2_nick"><a class="nick" href="/profil/1">%s</a></div>
+<div class="post_body">%s</div>
+</div>`
+	testTopicPagination = `<ul class="pagination_list"><li><a href="/temat/%d/0">1</a></li><li><a href="/temat/%d/%d">%d</a></li></ul>`
+
+	testForumIndex = `<html><body><table class="forum_list">
+<tr><td class="category">Gry</td></tr>
+<tr><td class="forum"><a href="/forum/1">StarCraft</a><span class="description">Brood War</span></td><td class="topics">1 234</td><td class="posts">56 789</td></tr>
+<tr><td class="forum"><a href="/forum/12">StarCraft II</a></td><td class="topics">12</td><td class="posts">345</td></tr>
+<tr><td class="category">Inne</td></tr>
+<tr><td class="forum"><a href="/forum/4">Off Topic</a><span class="description">Wszystko inne</span></td><td class="topics">7</td><td class="posts">8</td></tr>
+</table></body></html>`
+
+	testForumPage = `<html><body><table class="topic_list">
+<tr class="sticky locked"><td class="topic"><a href="/temat/10">Regulamin</a><span class="pages"><a href="/temat/10/0">1</a><a href="/temat/10/2">3</a></span></td>
+<td class="author"><a class="nick" href="/profil/1">alice</a><span class="date">2015-06-01 12:00:00</span></td>
+<td class="replies">45</td><td class="views">1 234</td>
+<td class="last_post"><a class="nick" href="/profil/2">bob</a><span class="date">2015-06-02 12:00:00</span></td></tr>
+<tr><td class="topic"><a href="/temat/11">Zergi</a></td>
+<td class="author"><span class="nick">gość</span><span class="date">2015-06-03 12:00:00</span></td>
+<td class="replies">0</td><td class="views">7</td>
+<td class="last_post"><span class="date">2015-06-03 12:00:00</span></td></tr>
+<tr><td class="topic"><a href="/temat/12">Protosi</a><span class="pages"><a href="/temat/12/0">?</a></span></td>
+<td class="author"><span class="nick">gość</span><span class="date"></span></td>
+<td class="replies">-</td><td class="views"></td>
+<td class="last_post"><span class="date">wczoraj</span></td></tr>
+</table></body></html>`
+
+	testUserProfile = `<html><body><div class="profil">
+<h2 class="nick">alice</h2><img class="avatar" src="/avatary/7.png">
+<span class="ranga">Weteran</span><span class="data_rejestracji">2004-03-01 10:00:00</span>
+<span class="liczba_postow">12 345</span><div class="podpis">gg</div>
+</div></body></html>`
+)
+
+// testTopicPage renders topic page that contains posts with given serials.
+func testTopicPage(topicID, pages int, serials ...int) string {
+	body := testTopicPageHead
+	if pages > 1 {
+		body += fmt.Sprintf(testTopicPagination, topicID, topicID, pages-1, pages)
+	}
+	for _, serial := range serials {
+		body += fmt.Sprintf(testTopicPost, 1000+serial, serial, serial, "nick", "content "+fmt.Sprint(serial))
+	}
+
+	return body + testTopicPageFoot
+}
+
+func setupTestClient(t *testing.T, pages map[string]string) (Client, *httptest.Server) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(rw, r)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(rw, page)
+	}))
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewClient(u, ClientOpts{}), server
+}
+
+func TestClient_FetchTopic_multiplePages(t *testing.T) {
+	client, server := setupTestClient(t, map[string]string{
+		"/temat/1":   testTopicPage(1, 3, 1, 2),
+		"/temat/1/1": testTopicPage(1, 3, 3, 4),
+		"/temat/1/2": testTopicPage(1, 3, 4, 5),
+	})
+	defer server.Close()
+
+	topic, err := client.FetchTopic(context.Background(), 1)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, 1, topic.ID)
+	assert.Equal(t, 12, topic.ForumID)
+	assert.Equal(t, 3, topic.Pages)
+	if assert.Len(t, topic.Posts, 5) {
+		for i, post := range topic.Posts {
+			assert.Equal(t, int64(i+1), post.Serial)
+			assert.Equal(t, "content "+fmt.Sprint(i+1), strings.TrimSpace(post.Content))
+			assert.Equal(t, 1, post.CreatedByID)
+		}
+	}
+	assert.Equal(t, "Test topic", topic.Title)
+	assert.Equal(t, "nick", topic.CreatedBy)
+	assert.Equal(t, topic.Posts[0].CreatedAt, topic.CreatedAt)
+	assert.Equal(t, 4, topic.Replies)
+}
+
+func TestClient_FetchTopic_missingPage(t *testing.T) {
+	client, server := setupTestClient(t, map[string]string{
+		"/temat/1":   testTopicPage(1, 3, 1, 2),
+		"/temat/1/1": testTopicPage(1, 3, 3, 4),
+	})
+	defer server.Close()
+
+	_, err := client.FetchTopic(context.Background(), 1)
+	assert.Error(t, err)
+}
+
+func TestClient_FetchTopic_deleted(t *testing.T) {
+	client, server := setupTestClient(t, map[string]string{
+		"/temat/2":  testTopicPage(2, 1),
+		"/forum/12": testTopicPageHead + testTopicPageFoot,
+	})
+	defer server.Close()
+
+	redirect := httptest.NewServer(http.RedirectHandler(server.URL+"/forum/12", http.StatusFound))
+	defer redirect.Close()
+
+	u, err := url.Parse(redirect.URL)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for name, c := range map[string]struct {
+		client Client
+		id     int
+	}{
+		"not found": {client: client, id: 1},
+		"redirect":  {client: NewClient(u, ClientOpts{}), id: 3},
+	} {
+		_, err := c.client.FetchTopic(context.Background(), c.id)
+		assert.Equal(t, ErrTopicDeleted, err, name)
+	}
+
+	_, err = client.FetchTopic(context.Background(), 2)
+	if assert.Error(t, err) {
+		assert.NotEqual(t, ErrTopicDeleted, err)
+	}
+}
+
+func TestClient_FetchTopicsForForum(t *testing.T) {
+	client, server := setupTestClient(t, map[string]string{
+		"/forum/12/0": testForumPage,
+	})
+	defer server.Close()
+
+	topics, err := client.FetchTopicsForForum(context.Background(), 12, 0)
+	if !assert.NoError(t, err) || !assert.Len(t, topics, 3) {
+		return
+	}
+
+	sticky := topics[0]
+	assert.Equal(t, 10, sticky.ID)
+	assert.Equal(t, 12, sticky.ForumID)
+	assert.Equal(t, "Regulamin", sticky.Title)
+	assert.Equal(t, 3, sticky.Pages)
+	assert.Equal(t, "alice", sticky.CreatedBy)
+	assert.Equal(t, 1, sticky.CreatedByID)
+	assert.Equal(t, 1, sticky.CreatedAt.Day())
+	assert.Equal(t, 45, sticky.Replies)
+	assert.Equal(t, 1234, sticky.Views)
+	assert.Equal(t, "bob", sticky.LastPostBy)
+	assert.Equal(t, 2, sticky.UpdatedAt.Day())
+	assert.True(t, sticky.Sticky)
+	assert.True(t, sticky.Locked)
+	assert.False(t, sticky.Announcement)
+	assert.NotNil(t, sticky.ListedAt)
+
+	guest := topics[1]
+	assert.Equal(t, 11, guest.ID)
+	assert.Equal(t, 1, guest.Pages)
+	assert.Equal(t, 0, guest.CreatedByID)
+	assert.Equal(t, 7, guest.Views)
+	assert.False(t, guest.Sticky)
+
+	// row with odd metadata is still listed, without it
+	odd := topics[2]
+	assert.Equal(t, 12, odd.ID)
+	assert.Equal(t, "Protosi", odd.Title)
+	assert.Equal(t, 1, odd.Pages)
+	assert.Nil(t, odd.CreatedAt)
+	assert.Nil(t, odd.UpdatedAt)
+	assert.Equal(t, 0, odd.Replies)
+	assert.Equal(t, 0, odd.Views)
+}
+
+func TestClient_FetchForums(t *testing.T) {
+	client, server := setupTestClient(t, map[string]string{
+		"/forum": testForumIndex,
+	})
+	defer server.Close()
+
+	forums, err := client.FetchForums(context.Background())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []*Forum{
+		{ID: 1, Name: "StarCraft", Description: "Brood War", Category: "Gry", Topics: 1234, Posts: 56789},
+		{ID: 12, Name: "StarCraft II", Category: "Gry", Topics: 12, Posts: 345},
+		{ID: 4, Name: "Off Topic", Description: "Wszystko inne", Category: "Inne", Topics: 7, Posts: 8},
+	}, forums)
+}
+
+func TestClient_FetchTopic_cancelled(t *testing.T) {
+	block := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, clientDefaultUserAgent, r.Header.Get("User-Agent"))
+		<-block
+	}))
+	defer server.Close()
+	defer close(block)
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = NewClient(u, ClientOpts{}).FetchTopic(ctx, 1)
+	assert.Error(t, err)
+}
+
+func TestClient_RefreshTopic(t *testing.T) {
+	pages := map[string]string{
+		"/temat/1":   testTopicPage(1, 3, 1, 2),
+		"/temat/1/1": testTopicPage(1, 3, 3, 4),
+		"/temat/1/2": testTopicPage(1, 3, 5),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		// only first page supports validators, others have to be compared by content
+		if r.URL.Path == "/temat/1" {
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				rw.WriteHeader(http.StatusNotModified)
+				return
+			}
+			rw.Header().Set("ETag", `"v1"`)
+		}
+
+		fmt.Fprint(rw, pages[r.URL.Path])
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(u, ClientOpts{})
+
+	topic, err := client.FetchTopic(context.Background(), 1)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = client.RefreshTopic(context.Background(), topic)
+	assert.Equal(t, ErrNotModified, err)
+
+	pages["/temat/1/2"] = testTopicPage(1, 3, 5, 6)
+
+	refreshed, err := client.RefreshTopic(context.Background(), topic)
+	if assert.NoError(t, err) {
+		assert.Len(t, refreshed.Posts, 6)
+		assert.Equal(t, topic.Title, refreshed.Title)
+		assert.True(t, topic.page(0).posts[0] == refreshed.page(0).posts[0], "unchanged pages should not be parsed again")
+	}
+}
+
+func TestClient_FetchUser(t *testing.T) {
+	client, server := setupTestClient(t, map[string]string{
+		"/profil/7": testUserProfile,
+	})
+	defer server.Close()
+
+	user, err := client.FetchUser(context.Background(), 7)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, 7, user.ID)
+	assert.Equal(t, "alice", user.Nick)
+	assert.Equal(t, server.URL+"/avatary/7.png", user.AvatarURL)
+	assert.Equal(t, "Weteran", user.Rank)
+	assert.Equal(t, 2004, user.JoinedAt.Year())
+	assert.Equal(t, 12345, user.PostCount)
+	assert.Equal(t, "gg", user.Signature)
+}
@@ -8,6 +8,12 @@ import (
 
 const (
 	contextKeyTopicStorage = "topic_storage"
+	contextKeyForumStorage = "forum_storage"
+	contextKeyUserStorage  = "user_storage"
+	contextKeySearchIndex  = "search_index"
+	contextKeyEventBroker  = "event_broker"
+	contextKeyWebhooks     = "webhooks"
+	contextKeyDeletions    = "deletions"
 )
 
 // NewTopicStorageContext returns a new Context that carries storage object.
@@ -25,3 +31,99 @@ func TopicStorageFromContext(ctx context.Context) (*TopicStore, error) {
 
 	return s, nil
 }
+
+// NewForumStorageContext returns a new Context that carries forum storage object.
+func NewForumStorageContext(ctx context.Context, storage *ForumStore) context.Context {
+	return context.WithValue(ctx, contextKeyForumStorage, storage)
+}
+
+// ForumStorageFromContext returns the forum storage stored in ctx, if any.
+func ForumStorageFromContext(ctx context.Context) (*ForumStore, error) {
+	s, ok := ctx.Value(contextKeyForumStorage).(*ForumStore)
+
+	if !ok {
+		return nil, errors.New("missing forum storage in context")
+	}
+
+	return s, nil
+}
+
+// NewUserStorageContext returns a new Context that carries user storage object.
+func NewUserStorageContext(ctx context.Context, storage *UserStore) context.Context {
+	return context.WithValue(ctx, contextKeyUserStorage, storage)
+}
+
+// UserStorageFromContext returns the user storage stored in ctx, if any.
+func UserStorageFromContext(ctx context.Context) (*UserStore, error) {
+	s, ok := ctx.Value(contextKeyUserStorage).(*UserStore)
+
+	if !ok {
+		return nil, errors.New("missing user storage in context")
+	}
+
+	return s, nil
+}
+
+// NewSearchIndexContext returns a new Context that carries search index.
+func NewSearchIndexContext(ctx context.Context, index *SearchIndex) context.Context {
+	return context.WithValue(ctx, contextKeySearchIndex, index)
+}
+
+// SearchIndexFromContext returns the search index stored in ctx, if any.
+func SearchIndexFromContext(ctx context.Context) (*SearchIndex, error) {
+	i, ok := ctx.Value(contextKeySearchIndex).(*SearchIndex)
+
+	if !ok {
+		return nil, errors.New("missing search index in context")
+	}
+
+	return i, nil
+}
+
+// NewEventBrokerContext returns a new Context that carries event broker.
+func NewEventBrokerContext(ctx context.Context, broker *EventBroker) context.Context {
+	return context.WithValue(ctx, contextKeyEventBroker, broker)
+}
+
+// EventBrokerFromContext returns the event broker stored in ctx, if any.
+func EventBrokerFromContext(ctx context.Context) (*EventBroker, error) {
+	b, ok := ctx.Value(contextKeyEventBroker).(*EventBroker)
+
+	if !ok {
+		return nil, errors.New("missing event broker in context")
+	}
+
+	return b, nil
+}
+
+// NewWebhookDispatcherContext returns a new Context that carries webhook dispatcher.
+func NewWebhookDispatcherContext(ctx context.Context, dispatcher *WebhookDispatcher) context.Context {
+	return context.WithValue(ctx, contextKeyWebhooks, dispatcher)
+}
+
+// WebhookDispatcherFromContext returns the webhook dispatcher stored in ctx, if any.
+func WebhookDispatcherFromContext(ctx context.Context) (*WebhookDispatcher, error) {
+	d, ok := ctx.Value(contextKeyWebhooks).(*WebhookDispatcher)
+
+	if !ok {
+		return nil, errors.New("missing webhook dispatcher in context")
+	}
+
+	return d, nil
+}
+
+// NewDeletionLogContext returns a new Context that carries deletion log.
+func NewDeletionLogContext(ctx context.Context, deletions *DeletionLog) context.Context {
+	return context.WithValue(ctx, contextKeyDeletions, deletions)
+}
+
+// DeletionLogFromContext returns the deletion log stored in ctx, if any.
+func DeletionLogFromContext(ctx context.Context) (*DeletionLog, error) {
+	d, ok := ctx.Value(contextKeyDeletions).(*DeletionLog)
+
+	if !ok {
+		return nil, errors.New("missing deletion log in context")
+	}
+
+	return d, nil
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// CrawlerOpts ...
+type CrawlerOpts struct {
+	// Workers is a number of topics fetched at once.
+	Workers int
+	// Retries is a number of additional attempts made after failed request.
+	Retries int
+	// Backoff is a delay before first retry, it doubles with every next attempt up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// Crawler fetches topics using bounded pool of workers.
+// Topic that cannot be fetched even after retries is reported through Err channel and does not stop the others.
+type Crawler struct {
+	sync.Mutex
+	retrier
+	client    Client
+	jobs      chan crawlJob
+	queue     []crawlJob
+	queued    chan struct{}
+	result    chan *Topic
+	unchanged chan int
+	deleted   chan int
+	err       chan error
+	pending   map[int]struct{}
+}
+
+// NewCrawler ...
+func NewCrawler(client Client, options CrawlerOpts) *Crawler {
+	if options.Workers < 1 {
+		options.Workers = 1
+	}
+
+	c := &Crawler{
+		retrier: retrier{
+			retries:    options.Retries,
+			backoff:    options.Backoff,
+			maxBackoff: options.MaxBackoff,
+		},
+		client:    client,
+		jobs:      make(chan crawlJob),
+		queued:    make(chan struct{}, 1),
+		result:    make(chan *Topic),
+		unchanged: make(chan int),
+		deleted:   make(chan int),
+		err:       make(chan error, 1),
+		pending:   make(map[int]struct{}),
+	}
+
+	go c.dispatch()
+	for i := 0; i < options.Workers; i++ {
+		go c.work()
+	}
+
+	return c
+}
+
+// topicError is sent through Err channel when topic cannot be fetched.
+type topicError struct {
+	id  int
+	err error
+}
+
+// Error implements error interface.
+func (te *topicError) Error() string {
+	return fmt.Sprintf("topic %d: %s", te.id, te.err.Error())
+}
+
+// crawlJob describes topic to fetch. If previous version is known, topic is refreshed conditionally.
+// Metadata from the forum listing, if given, is copied to the fetched topic.
+type crawlJob struct {
+	id      int
+	prev    *Topic
+	listing *Topic
+}
+
+// Enqueue schedules topic to be fetched. Topic that is already waiting in the queue is not added again,
+// so the queue never holds more than one job per topic. It does not wait for busy workers.
+func (c *Crawler) Enqueue(id int) {
+	c.enqueue(crawlJob{id: id})
+}
+
+// Refresh schedules topic to be fetched again. If it did not change, nothing is sent to the Result channel.
+func (c *Crawler) Refresh(topic *Topic) {
+	c.enqueue(crawlJob{id: topic.ID, prev: topic})
+}
+
+func (c *Crawler) enqueue(job crawlJob) {
+	c.Lock()
+	if _, ok := c.pending[job.id]; ok {
+		c.Unlock()
+		return
+	}
+	c.pending[job.id] = struct{}{}
+	c.queue = append(c.queue, job)
+	c.Unlock()
+
+	select {
+	case c.queued <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch hands queued jobs over to workers in order they were enqueued.
+func (c *Crawler) dispatch() {
+	for range c.queued {
+		for {
+			c.Lock()
+			if len(c.queue) == 0 {
+				c.Unlock()
+				break
+			}
+			job := c.queue[0]
+			c.queue[0] = crawlJob{}
+			c.queue = c.queue[1:]
+			c.Unlock()
+
+			c.jobs <- job
+		}
+	}
+}
+
+// Crawl walks first nbOfPages pages of every given forum and enqueues all topics found there.
+// Forum or page that cannot be fetched is reported through Err channel and skipped.
+func (c *Crawler) Crawl(ctx context.Context, forumIDs []int, nbOfPages int) {
+	for _, forumID := range forumIDs {
+		var pages int
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		err := c.retry(ctx, func() (err error) {
+			pages, err = c.client.FetchForumPages(ctx, forumID)
+			return
+		})
+		if err != nil {
+			c.err <- fmt.Errorf("forum %d: %s", forumID, err.Error())
+			continue
+		}
+
+		if pages > nbOfPages {
+			pages = nbOfPages
+		}
+
+		for pageID := 0; pageID < pages; pageID++ {
+			var topics []*Topic
+
+			err := c.retry(ctx, func() (err error) {
+				topics, err = c.client.FetchTopicsForForum(ctx, forumID, pageID)
+				return
+			})
+			if err != nil {
+				c.err <- fmt.Errorf("forum %d page %d: %s", forumID, pageID, err.Error())
+				continue
+			}
+
+			for _, topic := range topics {
+				c.enqueue(crawlJob{id: topic.ID, listing: topic})
+			}
+		}
+	}
+}
+
+// Result returns channel that receives successfully fetched topics.
+func (c *Crawler) Result() <-chan *Topic {
+	return c.result
+}
+
+// Unchanged returns channel that receives ids of refreshed topics that did not change.
+func (c *Crawler) Unchanged() <-chan int {
+	return c.unchanged
+}
+
+// Deleted returns channel that receives ids of topics that do not exist anymore.
+func (c *Crawler) Deleted() <-chan int {
+	return c.deleted
+}
+
+// Err ...
+func (c *Crawler) Err() <-chan error {
+	return c.err
+}
+
+func (c *Crawler) work() {
+	ctx := context.Background()
+
+	for job := range c.jobs {
+		var topic *Topic
+
+		err := c.retry(ctx, func() (err error) {
+			if job.prev != nil {
+				topic, err = c.client.RefreshTopic(ctx, job.prev)
+			} else {
+				topic, err = c.client.FetchTopic(ctx, job.id)
+			}
+			return
+		})
+
+		c.Lock()
+		delete(c.pending, job.id)
+		c.Unlock()
+
+		switch err {
+		case nil:
+		case ErrNotModified:
+			c.unchanged <- job.id
+			continue
+		case ErrTopicDeleted:
+			c.deleted <- job.id
+			continue
+		default:
+			c.err <- &topicError{id: job.id, err: err}
+			continue
+		}
+
+		topic.applyListing(job.listing)
+		c.result <- topic
+	}
+}
+
+// retrier repeats failed requests with exponential backoff.
+type retrier struct {
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+// retry calls fn until it succeeds, number of retries is exceeded or context is done.
+// Deleted topic is not going to come back, so it is not retried.
+// Between attempts it sleeps with exponential backoff.
+func (r retrier) retry(ctx context.Context, fn func() error) (err error) {
+	backoff := r.backoff
+
+	for attempt := 0; ; attempt++ {
+		if err = fn(); err == nil || err == ErrNotModified || err == ErrTopicDeleted || attempt >= r.retries || ctx.Err() != nil {
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+
+		backoff *= 2
+		if r.maxBackoff > 0 && backoff > r.maxBackoff {
+			backoff = r.maxBackoff
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestCrawler_Crawl(t *testing.T) {
+	client := &ClientMock{}
+	client.On("FetchForumPages", 1).Return(5, nil)
+	client.On("FetchForumPages", 2).Return(0, errors.New("forum unavailable"))
+	client.On("FetchTopicsForForum", 1, 0).Return([]*Topic{{ID: 10}, {ID: 11}}, nil)
+	client.On("FetchTopicsForForum", 1, 1).Return([]*Topic{{ID: 12}}, nil)
+	client.On("FetchTopic", 10).Return(&Topic{ID: 10}, nil)
+	client.On("FetchTopic", 11).Return(nil, errors.New("topic unavailable"))
+	client.On("FetchTopic", 12).Return(&Topic{ID: 12}, nil)
+
+	crawler := NewCrawler(client, CrawlerOpts{
+		Workers: 2,
+		Retries: 2,
+		Backoff: time.Millisecond,
+	})
+	go crawler.Crawl(context.Background(), []int{2, 1}, 2)
+
+	var fetched []int
+	var errs []error
+
+	timeout := time.After(5 * time.Second)
+	for len(fetched)+len(errs) < 4 {
+		select {
+		case topic := <-crawler.Result():
+			fetched = append(fetched, topic.ID)
+		case err := <-crawler.Err():
+			errs = append(errs, err)
+		case <-timeout:
+			t.Fatalf("crawler timed out, fetched: %v, errors: %v", fetched, errs)
+		}
+	}
+
+	sort.Ints(fetched)
+	assert.Equal(t, []int{10, 12}, fetched)
+	assert.Len(t, errs, 2)
+	client.AssertNumberOfCalls(t, "FetchForumPages", 4)
+	client.AssertNumberOfCalls(t, "FetchTopic", 5)
+}
+
+func TestCrawler_Enqueue(t *testing.T) {
+	client := &ClientMock{}
+	for id := 1; id <= 10; id++ {
+		client.On("FetchTopic", id).Return(&Topic{ID: id}, nil)
+	}
+
+	crawler := NewCrawler(client, CrawlerOpts{Workers: 1})
+
+	// nobody reads results, so the only worker gets stuck, but the queue still accepts topics
+	done := make(chan struct{})
+	go func() {
+		for id := 1; id <= 10; id++ {
+			crawler.Enqueue(id)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("enqueue should not wait for workers")
+	}
+
+	var fetched []int
+	for len(fetched) < 10 {
+		select {
+		case topic := <-crawler.Result():
+			fetched = append(fetched, topic.ID)
+		case <-time.After(time.Second):
+			t.Fatalf("crawler timed out, fetched: %v", fetched)
+		}
+	}
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, fetched)
+}
+
+func TestThrottle_reserve(t *testing.T) {
+	throttle := NewThrottle(ThrottleOpts{
+		RequestsPerSecond: 10,
+		HostDelay:         time.Second,
+	})
+
+	assert.Equal(t, time.Duration(0), throttle.reserve("a"))
+	assert.InDelta(t, 100*time.Millisecond, throttle.reserve("b"), float64(10*time.Millisecond))
+	assert.InDelta(t, time.Second, throttle.reserve("a"), float64(10*time.Millisecond))
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Types of Deletion.
+const (
+	DeletionPost  = "post"
+	DeletionTopic = "topic"
+)
+
+// Deletion records post or topic that disappeared from the forum.
+type Deletion struct {
+	Type      string    `json:"type"`
+	TopicID   int       `json:"topicId"`
+	ForumID   int       `json:"forumId"`
+	Serial    int64     `json:"serial,omitempty"`
+	Title     string    `json:"title"`
+	Author    string    `json:"author,omitempty"`
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+// DeletionLog keeps the most recent deletions, the oldest ones are dropped once capacity is reached.
+type DeletionLog struct {
+	sync.RWMutex
+	capacity  int
+	deletions []*Deletion
+}
+
+// NewDeletionLog ...
+func NewDeletionLog(capacity int) *DeletionLog {
+	return &DeletionLog{
+		capacity:  capacity,
+		deletions: make([]*Deletion, 0),
+	}
+}
+
+// Add ...
+func (dl *DeletionLog) Add(deletions ...*Deletion) {
+	dl.Lock()
+	defer dl.Unlock()
+
+	dl.deletions = append(dl.deletions, deletions...)
+	if dl.capacity > 0 && len(dl.deletions) > dl.capacity {
+		dl.deletions = append([]*Deletion(nil), dl.deletions[len(dl.deletions)-dl.capacity:]...)
+	}
+}
+
+// List returns deletions newest first. They can be narrowed to a single forum and to those that happened after given time.
+// Negative limit gives no deletions.
+func (dl *DeletionLog) List(forumID int, since time.Time, offset, limit int) []*Deletion {
+	dl.RLock()
+	defer dl.RUnlock()
+
+	size := limit
+	if size > len(dl.deletions) {
+		size = len(dl.deletions)
+	}
+	if size < 0 {
+		size = 0
+	}
+	deletions := make([]*Deletion, 0, size)
+	for i := len(dl.deletions) - 1; i >= 0 && len(deletions) < limit; i-- {
+		d := dl.deletions[i]
+		if (forumID != 0 && d.ForumID != forumID) || !d.DeletedAt.After(since) {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+
+		deletions = append(deletions, d)
+	}
+
+	return deletions
+}
+
+// tombstonePosts keeps posts of the previous version that are missing from the next one,
+// marked with deletion time. Posts deleted earlier are carried over as they are.
+// It returns posts that are found deleted for the first time.
+func tombstonePosts(prev, next *Topic, deletedAt time.Time) []*Post {
+	if prev == nil {
+		return nil
+	}
+
+	present := make(map[int64]struct{}, len(next.Posts))
+	for _, post := range next.Posts {
+		present[post.Serial] = struct{}{}
+	}
+
+	var deleted []*Post
+	posts := postsBySerial(next.Posts)
+
+	for _, post := range prev.Posts {
+		if _, ok := present[post.Serial]; ok {
+			continue
+		}
+		if post.DeletedAt == nil {
+			tombstone := *post
+			tombstone.DeletedAt = &deletedAt
+			post = &tombstone
+			deleted = append(deleted, post)
+		}
+
+		posts = append(posts, post)
+	}
+
+	if len(posts) != len(next.Posts) {
+		sort.Sort(posts)
+		next.Posts = posts
+	}
+
+	return deleted
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"github.com/piotrkowalczuk/rest"
+	"golang.org/x/net/context"
+)
+
+// DeletionsGetEndpoint returns posts and topics removed from the forum, newest first.
+func DeletionsGetEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(DeletionsGetRequest)
+	if !ok {
+		return nil, rest.InternalServerError(errBadCast, internalServerErrorMessage, 0)
+	}
+
+	deletions, err := DeletionLogFromContext(ctx)
+	if err != nil {
+		return nil, rest.InternalServerError(err, internalServerErrorMessage, 0)
+	}
+
+	return deletions.List(req.ForumID, req.Since, req.Offset, req.Limit), nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/piotrkowalczuk/rest"
+	"golang.org/x/net/context"
+)
+
+// DeletionsGetRequest ...
+type DeletionsGetRequest struct {
+	ForumID int
+	Since   time.Time
+	Offset  int
+	Limit   int
+}
+
+// DeletionsGetRequestDecode ...
+func DeletionsGetRequestDecode(ctx context.Context, r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+
+	forumID, err := strconv.ParseInt(query.Get("forumId"), 10, 32)
+	if err != nil {
+		forumID = 0
+	}
+
+	offset, limit, err := parsePage(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var since time.Time
+	if query.Get("since") != "" {
+		if since, err = time.Parse(time.RFC3339, query.Get("since")); err != nil {
+			return nil, &rest.Error{Message: "since has to be in RFC 3339 format", HTTPCode: http.StatusBadRequest}
+		}
+	}
+
+	return DeletionsGetRequest{
+		ForumID: int(forumID),
+		Since:   since,
+		Offset:  offset,
+		Limit:   limit,
+	}, nil
+}
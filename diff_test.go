@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	cases := map[string]struct {
+		from, to, expected string
+	}{
+		"same": {
+			from: "a\nb",
+			to:   "a\nb",
+		},
+		"changed line": {
+			from:     "a\nb\nc",
+			to:       "a\nB\nc",
+			expected: "--- v1\n+++ v2\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		"appended to empty": {
+			from:     "",
+			to:       "a",
+			expected: "--- v1\n+++ v2\n@@ -0,0 +1 @@\n+a\n",
+		},
+		"distant changes": {
+			from:     "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12",
+			to:       "0\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n13",
+			expected: "--- v1\n+++ v2\n@@ -1,4 +1,4 @@\n-1\n+0\n 2\n 3\n 4\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+13\n",
+		},
+		"close changes": {
+			from:     "1\n2\n3\n4\n5",
+			to:       "0\n2\n3\n4\n6",
+			expected: "--- v1\n+++ v2\n@@ -1,5 +1,5 @@\n-1\n+0\n 2\n 3\n 4\n-5\n+6\n",
+		},
+	}
+
+	for name, c := range cases {
+		assert.Equal(t, c.expected, unifiedDiff(c.from, c.to, "v1", "v2"), name)
+	}
+}
+
+func TestUnifiedDiff_tooLarge(t *testing.T) {
+	var from, to []string
+	for i := 0; i < 2000; i++ {
+		from = append(from, "a"+strconv.Itoa(i))
+		to = append(to, "b"+strconv.Itoa(i))
+	}
+
+	assert.Equal(t, "--- v1\n+++ v2\n"+diffTooLarge+"\n", unifiedDiff(strings.Join(from, "\n"), strings.Join(to, "\n"), "v1", "v2"))
+
+	// long texts with a small change are still compared
+	to = append([]string{}, from...)
+	to[1000] = "changed"
+	assert.Equal(t,
+		"--- v1\n+++ v2\n@@ -998,7 +998,7 @@\n a997\n a998\n a999\n-a1000\n+changed\n a1001\n a1002\n a1003\n",
+		unifiedDiff(strings.Join(from, "\n"), strings.Join(to, "\n"), "v1", "v2"),
+	)
+}
+
+func TestTrackRevisions_limit(t *testing.T) {
+	var prev *Topic
+	for i := 0; i < postRevisionsLimit+5; i++ {
+		next := &Topic{Posts: []*Post{{Serial: 1, HTML: strconv.Itoa(i)}}}
+		trackRevisions(prev, next, time.Now())
+		prev = next
+	}
+
+	revisions := prev.Posts[0].Revisions()
+	if assert.Len(t, revisions, postRevisionsLimit) {
+		assert.Equal(t, 6, revisions[0].Version)
+		assert.Equal(t, postRevisionsLimit+5, revisions[len(revisions)-1].Version)
+	}
+}
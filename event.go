@@ -0,0 +1,95 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// Types of Event.
+const (
+	EventPostCreated  = "post.created"
+	EventPostEdited   = "post.edited"
+	EventPostDeleted  = "post.deleted"
+	EventTopicRenamed = "topic.renamed"
+	EventTopicDeleted = "topic.deleted"
+)
+
+// Event describes single change noticed between two consecutive versions of the topic.
+type Event struct {
+	ID            uint64    `json:"id"`
+	Type          string    `json:"type"`
+	TopicID       int       `json:"topicId"`
+	ForumID       int       `json:"forumId"`
+	Serial        int64     `json:"serial,omitempty"`
+	Post          *Post     `json:"post,omitempty"`
+	Title         string    `json:"title,omitempty"`
+	PreviousTitle string    `json:"previousTitle,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// diffTopics compares two versions of the same topic and returns events that describe what changed.
+// Nothing is returned if previous version is unknown.
+func diffTopics(prev, next *Topic) []*Event {
+	if prev == nil || next == nil {
+		return nil
+	}
+
+	var events []*Event
+
+	now := time.Now()
+	event := func(typ string, post *Post) *Event {
+		e := &Event{
+			Type:      typ,
+			TopicID:   next.ID,
+			ForumID:   next.ForumID,
+			Post:      post,
+			CreatedAt: now,
+		}
+		if post != nil {
+			e.Serial = post.Serial
+		}
+		return e
+	}
+
+	if prev.Title != next.Title {
+		e := event(EventTopicRenamed, nil)
+		e.Title = next.Title
+		e.PreviousTitle = prev.Title
+		events = append(events, e)
+	}
+
+	prevPosts := make(map[int64]*Post, len(prev.Posts))
+	for _, post := range prev.Posts {
+		prevPosts[post.Serial] = post
+	}
+
+	deleted := make(postsBySerial, 0)
+
+	for _, post := range next.Posts {
+		old, ok := prevPosts[post.Serial]
+		switch {
+		case post.DeletedAt != nil:
+			// tombstone, reported only once
+			if ok && old.DeletedAt == nil {
+				deleted = append(deleted, post)
+			}
+		case !ok:
+			events = append(events, event(EventPostCreated, post))
+		case old.Content != post.Content || old.HTML != post.HTML:
+			events = append(events, event(EventPostEdited, post))
+		}
+		delete(prevPosts, post.Serial)
+	}
+
+	for _, post := range prevPosts {
+		if post.DeletedAt == nil {
+			deleted = append(deleted, post)
+		}
+	}
+	sort.Sort(deleted)
+	for _, post := range deleted {
+		events = append(events, event(EventPostDeleted, post))
+	}
+
+	return events
+}
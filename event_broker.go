@@ -0,0 +1,105 @@
+package main
+
+import (
+	"sync"
+)
+
+const (
+	// eventSubscriptionBuffer is a number of events that can wait for slow subscriber before it is dropped.
+	eventSubscriptionBuffer = 100
+)
+
+// EventBroker assigns identifiers to published events and fans them out to subscribers.
+// Recent events are kept in a ring buffer, so subscriber that reconnects can resume where it stopped.
+type EventBroker struct {
+	sync.RWMutex
+	lastID      uint64
+	recent      []*Event
+	next        int
+	subscribers map[*EventSubscription]struct{}
+}
+
+// EventSubscription receives events published after it was created.
+// Channel is closed if subscriber cannot keep up or when it unsubscribes.
+type EventSubscription struct {
+	C      <-chan *Event
+	c      chan *Event
+	closed bool
+}
+
+// NewEventBroker ...
+func NewEventBroker(capacity int) *EventBroker {
+	return &EventBroker{
+		recent:      make([]*Event, capacity),
+		subscribers: make(map[*EventSubscription]struct{}),
+	}
+}
+
+// Publish assigns identifiers to events and delivers them to all subscribers.
+func (eb *EventBroker) Publish(events ...*Event) {
+	eb.Lock()
+	defer eb.Unlock()
+
+	for _, event := range events {
+		eb.lastID++
+		event.ID = eb.lastID
+
+		if len(eb.recent) > 0 {
+			eb.recent[eb.next] = event
+			eb.next = (eb.next + 1) % len(eb.recent)
+		}
+
+		for sub := range eb.subscribers {
+			select {
+			case sub.c <- event:
+			default:
+				eb.unsubscribe(sub)
+			}
+		}
+	}
+}
+
+// Subscribe creates subscription. If lastEventID is given, events published after it
+// that are still kept in the buffer are returned, so they can be replayed before live ones.
+func (eb *EventBroker) Subscribe(lastEventID uint64) (*EventSubscription, []*Event) {
+	c := make(chan *Event, eventSubscriptionBuffer)
+	sub := &EventSubscription{C: c, c: c}
+
+	eb.Lock()
+	defer eb.Unlock()
+
+	eb.subscribers[sub] = struct{}{}
+
+	if lastEventID == 0 {
+		return sub, nil
+	}
+
+	backlog := make([]*Event, 0)
+	for i := 0; i < len(eb.recent); i++ {
+		event := eb.recent[(eb.next+i)%len(eb.recent)]
+		if event != nil && event.ID > lastEventID {
+			backlog = append(backlog, event)
+		}
+	}
+
+	return sub, backlog
+}
+
+// Unsubscribe ...
+func (eb *EventBroker) Unsubscribe(sub *EventSubscription) {
+	eb.Lock()
+	defer eb.Unlock()
+
+	eb.unsubscribe(sub)
+}
+
+// unsubscribe is not thread safe!
+func (eb *EventBroker) unsubscribe(sub *EventSubscription) {
+	if sub.closed {
+		return
+	}
+
+	sub.closed = true
+	delete(eb.subscribers, sub)
+	close(sub.c)
+}
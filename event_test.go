@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestDiffTopics(t *testing.T) {
+	prev := &Topic{ID: 1, ForumID: 2, Title: "old", Posts: []*Post{
+		{Serial: 1, Content: "a"},
+		{Serial: 2, Content: "b"},
+		{Serial: 3, Content: "c"},
+	}}
+	next := &Topic{ID: 1, ForumID: 2, Title: "new", Posts: []*Post{
+		{Serial: 1, Content: "a"},
+		{Serial: 3, Content: "c, edited"},
+		{Serial: 4, Content: "d"},
+	}}
+
+	assert.Nil(t, diffTopics(nil, next))
+
+	events := diffTopics(prev, next)
+	if assert.Len(t, events, 4) {
+		assert.Equal(t, EventTopicRenamed, events[0].Type)
+		assert.Equal(t, "old", events[0].PreviousTitle)
+		assert.Equal(t, EventPostEdited, events[1].Type)
+		assert.Equal(t, int64(3), events[1].Serial)
+		assert.Equal(t, EventPostCreated, events[2].Type)
+		assert.Equal(t, int64(4), events[2].Serial)
+		assert.Equal(t, EventPostDeleted, events[3].Type)
+		assert.Equal(t, int64(2), events[3].Serial)
+	}
+}
+
+func TestEventBroker_Subscribe(t *testing.T) {
+	broker := NewEventBroker(2)
+	broker.Publish(&Event{Type: EventPostCreated}, &Event{Type: EventPostCreated}, &Event{Type: EventPostCreated})
+
+	sub, backlog := broker.Subscribe(1)
+	if assert.Len(t, backlog, 2) {
+		assert.Equal(t, uint64(2), backlog[0].ID)
+		assert.Equal(t, uint64(3), backlog[1].ID)
+	}
+
+	broker.Publish(&Event{Type: EventPostEdited})
+	e := <-sub.C
+	assert.Equal(t, uint64(4), e.ID)
+
+	broker.Unsubscribe(sub)
+	_, open := <-sub.C
+	assert.False(t, open)
+}
+
+func TestEventsGetHandler(t *testing.T) {
+	broker := NewEventBroker(10)
+	broker.Publish(
+		&Event{Type: EventPostCreated, TopicID: 1, Serial: 1},
+		&Event{Type: EventPostCreated, TopicID: 2, Serial: 1},
+		&Event{Type: EventPostCreated, TopicID: 1, Serial: 2},
+	)
+
+	router := httprouter.New()
+	router.GET("/events", EventsGetHandler(NewEventBrokerContext(context.Background(), broker)))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL+"/events?topicId=1", nil)
+	req.Header.Set("Last-Event-ID", "1")
+
+	res, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer res.Body.Close()
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	broker.Publish(&Event{Type: EventPostEdited, TopicID: 1, Serial: 2})
+
+	var ids []string
+	scanner := bufio.NewScanner(res.Body)
+	for len(ids) < 2 && scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "id: ") {
+			ids = append(ids, line[4:])
+		}
+	}
+
+	assert.Equal(t, []string{"3", "4"}, ids)
+}
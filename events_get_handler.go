@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/net/context"
+)
+
+const (
+	eventsHeartbeatInterval = 15 * time.Second
+)
+
+// EventsGetHandler streams topic changes as Server-Sent Events.
+// Stream can be narrowed with topicId and forumId query parameters and resumed with Last-Event-ID header.
+func EventsGetHandler(ctx context.Context) httprouter.Handle {
+	return func(rw http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		broker, err := EventBrokerFromContext(ctx)
+		if err != nil {
+			log.Println(err)
+			http.Error(rw, internalServerErrorMessage, http.StatusInternalServerError)
+			return
+		}
+
+		flusher, ok := rw.(http.Flusher)
+		if !ok {
+			http.Error(rw, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		query := r.URL.Query()
+		topicID, _ := strconv.ParseInt(query.Get("topicId"), 10, 32)
+		forumID, _ := strconv.ParseInt(query.Get("forumId"), 10, 32)
+
+		lastEventID := r.Header.Get("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = query.Get("lastEventId")
+		}
+		since, _ := strconv.ParseUint(lastEventID, 10, 64)
+
+		matches := func(e *Event) bool {
+			return (topicID == 0 || e.TopicID == int(topicID)) && (forumID == 0 || e.ForumID == int(forumID))
+		}
+
+		sub, backlog := broker.Subscribe(since)
+		defer broker.Unsubscribe(sub)
+
+		rw.Header().Set("Content-Type", "text/event-stream")
+		rw.Header().Set("Cache-Control", "no-cache")
+		rw.Header().Set("Connection", "keep-alive")
+		rw.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		for _, e := range backlog {
+			if matches(e) {
+				if err := writeServerSentEvent(rw, e); err != nil {
+					return
+				}
+			}
+		}
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(eventsHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case e, open := <-sub.C:
+				if !open {
+					return
+				}
+				if !matches(e) {
+					continue
+				}
+				if err := writeServerSentEvent(rw, e); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(rw, ": ping\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+}
+
+func writeServerSentEvent(rw http.ResponseWriter, e *Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(rw, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+
+	return err
+}
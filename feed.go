@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/xml"
+	"strconv"
+	"time"
+)
+
+const (
+	// feedTagPrefix starts every feed and entry identifier, see RFC 4151.
+	feedTagPrefix = "tag:netwars.pl,2005:"
+	feedSize      = 50
+)
+
+// Feed is a format independent representation of Atom and RSS feeds.
+type Feed struct {
+	ID      string
+	Title   string
+	Link    string
+	Self    string
+	Updated time.Time
+	Entries []*FeedEntry
+}
+
+// FeedEntry ...
+type FeedEntry struct {
+	ID        string
+	Title     string
+	Link      string
+	Author    string
+	Published time.Time
+	Updated   time.Time
+	HTML      string
+}
+
+// NewTopicsFeed builds feed with an entry per topic, content of the entry is the opening post.
+func NewTopicsFeed(id, title string, topics []*Topic) *Feed {
+	feed := &Feed{ID: feedTagPrefix + id, Title: title, Link: netwarsURL + "/forum"}
+
+	for _, topic := range topics {
+		entry := &FeedEntry{
+			ID:    feedTagPrefix + "temat/" + strconv.FormatInt(int64(topic.ID), 10),
+			Title: topic.Title,
+			Link:  topicLink(topic.ID),
+		}
+		if len(topic.Posts) > 0 {
+			first := topic.Posts[0]
+			entry.Author = first.CreatedBy
+			entry.HTML = first.HTML
+			if first.CreatedAt != nil {
+				entry.Published = *first.CreatedAt
+			}
+		}
+		if topic.UpdatedAt != nil {
+			entry.Updated = *topic.UpdatedAt
+		}
+		if entry.Updated.IsZero() {
+			entry.Updated = entry.Published
+		}
+
+		feed.add(entry)
+	}
+
+	return feed
+}
+
+// NewPostsFeed builds feed with an entry per post. Posts are expected to be ordered newest first.
+func NewPostsFeed(id, title, link string, posts []*Post) *Feed {
+	feed := &Feed{ID: feedTagPrefix + id, Title: title, Link: link}
+
+	for _, post := range posts {
+		entry := &FeedEntry{
+			ID:     feedTagPrefix + "temat/" + strconv.FormatInt(post.TopicID, 10) + "/" + strconv.FormatInt(post.Serial, 10),
+			Title:  "#" + strconv.FormatInt(post.Serial, 10) + " " + post.CreatedBy,
+			Link:   topicLink(int(post.TopicID)),
+			Author: post.CreatedBy,
+			HTML:   post.HTML,
+		}
+		if post.ID != 0 {
+			entry.Link += "#post_" + strconv.FormatInt(post.ID, 10)
+		}
+		if post.CreatedAt != nil {
+			entry.Published = *post.CreatedAt
+			entry.Updated = *post.CreatedAt
+		}
+		if post.Modified && post.ModifiedAt != nil {
+			entry.Updated = *post.ModifiedAt
+		}
+
+		feed.add(entry)
+	}
+
+	return feed
+}
+
+func (f *Feed) add(entry *FeedEntry) {
+	if entry.Updated.After(f.Updated) {
+		f.Updated = entry.Updated
+	}
+
+	f.Entries = append(f.Entries, entry)
+}
+
+// Atom renders feed as Atom 1.0 document, see RFC 4287.
+func (f *Feed) Atom() ([]byte, error) {
+	doc := atomFeed{
+		ID:      f.ID,
+		Title:   f.Title,
+		Updated: f.Updated.UTC().Format(time.RFC3339),
+		Author:  &atomPerson{Name: "netwars.pl"},
+		Links:   []atomLink{{Rel: "alternate", Href: f.Link}},
+	}
+	if f.Self != "" {
+		doc.Links = append(doc.Links, atomLink{Rel: "self", Href: f.Self})
+	}
+
+	for _, e := range f.Entries {
+		entry := atomEntry{
+			ID:      e.ID,
+			Title:   e.Title,
+			Updated: e.Updated.UTC().Format(time.RFC3339),
+			Links:   []atomLink{{Rel: "alternate", Href: e.Link}},
+			Content: atomText{Type: "html", Body: e.HTML},
+		}
+		if !e.Published.IsZero() {
+			entry.Published = e.Published.UTC().Format(time.RFC3339)
+		}
+		if e.Author != "" {
+			entry.Author = &atomPerson{Name: e.Author}
+		}
+
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	return marshalFeed(doc)
+}
+
+// RSS renders feed as RSS 2.0 document.
+func (f *Feed) RSS() ([]byte, error) {
+	doc := rssDocument{
+		Version: "2.0",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        f.Link,
+			Description: f.Title,
+		},
+	}
+	if !f.Updated.IsZero() {
+		doc.Channel.LastBuildDate = f.Updated.Format(time.RFC1123Z)
+	}
+	if f.Self != "" {
+		doc.Channel.Self = &atomLink{Rel: "self", Href: f.Self, Type: "application/rss+xml"}
+	}
+
+	for _, e := range f.Entries {
+		item := rssItem{
+			Title:       e.Title,
+			Link:        e.Link,
+			GUID:        rssGUID{IsPermaLink: "false", Value: e.ID},
+			Creator:     e.Author,
+			Description: e.HTML,
+		}
+		if !e.Published.IsZero() {
+			item.PubDate = e.Published.Format(time.RFC1123Z)
+		}
+
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+
+	return marshalFeed(doc)
+}
+
+func marshalFeed(doc interface{}) ([]byte, error) {
+	b, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), b...), nil
+}
+
+func topicLink(topicID int) string {
+	return netwarsURL + "/temat/" + strconv.FormatInt(int64(topicID), 10)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  *atomPerson `xml:"author,omitempty"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Published string      `xml:"published,omitempty"`
+	Updated   string      `xml:"updated"`
+	Author    *atomPerson `xml:"author,omitempty"`
+	Links     []atomLink  `xml:"link"`
+	Content   atomText    `xml:"content"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Self          *atomLink `xml:"atom:link,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	Creator     string  `xml:"dc:creator,omitempty"`
+	PubDate     string  `xml:"pubDate,omitempty"`
+	Description string  `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
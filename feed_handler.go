@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/net/context"
+)
+
+const (
+	feedFormatAtom = "atom"
+	feedFormatRSS  = "rss"
+)
+
+// TopicsFeedHandler serves the most recently updated topics.
+func TopicsFeedHandler(ctx context.Context, format string) httprouter.Handle {
+	return func(rw http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		storage, err := TopicStorageFromContext(ctx)
+		if err != nil {
+			writeError(rw, err)
+			return
+		}
+
+		topics, err := storage.Latest(feedSize)
+		if err != nil {
+			writeError(rw, err)
+			return
+		}
+
+		serveFeed(rw, r, NewTopicsFeed("topics", "netwars.pl - najnowsze tematy", topics), format)
+	}
+}
+
+// ForumFeedHandler serves the most recently updated topics of a single forum.
+func ForumFeedHandler(ctx context.Context, format string) httprouter.Handle {
+	return func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		forumID, err := strconv.ParseInt(params.ByName("forumId"), 10, 32)
+		if err != nil {
+			http.Error(rw, "malformed forum id", http.StatusBadRequest)
+			return
+		}
+
+		storage, err := TopicStorageFromContext(ctx)
+		if err != nil {
+			writeError(rw, err)
+			return
+		}
+
+		topics, err := storage.LatestByForum(int(forumID), feedSize)
+		if err != nil {
+			writeError(rw, err)
+			return
+		}
+
+		title := "netwars.pl - forum " + strconv.FormatInt(forumID, 10)
+		if forums, err := storage.forums.List(r.Context()); err == nil {
+			for _, forum := range forums {
+				if forum.ID == int(forumID) {
+					title = "netwars.pl - " + forum.Name
+				}
+			}
+		}
+
+		feed := NewTopicsFeed("forum/"+strconv.FormatInt(forumID, 10), title, topics)
+		feed.Link = netwarsURL + "/forum/" + strconv.FormatInt(forumID, 10)
+
+		serveFeed(rw, r, feed, format)
+	}
+}
+
+// TopicFeedHandler serves the most recent posts of the topic.
+func TopicFeedHandler(ctx context.Context, format string) httprouter.Handle {
+	return func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		topicID, err := strconv.ParseInt(params.ByName("topicId"), 10, 32)
+		if err != nil {
+			http.Error(rw, "malformed topic id", http.StatusBadRequest)
+			return
+		}
+
+		storage, err := TopicStorageFromContext(ctx)
+		if err != nil {
+			writeError(rw, err)
+			return
+		}
+
+		// cached topic is read without extending its expiration, readers poll feeds all the time
+		topic, ok := storage.Peek(int(topicID))
+		if !ok {
+			if topic, err = storage.GetOrRetrieve(r.Context(), int(topicID)); err != nil {
+				writeError(rw, err)
+				return
+			}
+		}
+
+		posts := make([]*Post, 0, feedSize)
+		for i := len(topic.Posts) - 1; i >= 0 && len(posts) < feedSize; i-- {
+			posts = append(posts, topic.Posts[i])
+		}
+
+		feed := NewPostsFeed("temat/"+strconv.FormatInt(topicID, 10), topic.Title, topicLink(topic.ID), posts)
+
+		serveFeed(rw, r, feed, format)
+	}
+}
+
+// UserFeedHandler serves the most recent posts of the user found in cached topics.
+func UserFeedHandler(ctx context.Context, format string) httprouter.Handle {
+	return func(rw http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		userID, err := strconv.ParseInt(params.ByName("userId"), 10, 32)
+		if err != nil {
+			http.Error(rw, "malformed user id", http.StatusBadRequest)
+			return
+		}
+
+		storage, err := UserStorageFromContext(ctx)
+		if err != nil {
+			writeError(rw, err)
+			return
+		}
+
+		posts, err := storage.Posts(int(userID), 0, feedSize)
+		if err != nil {
+			writeError(rw, err)
+			return
+		}
+
+		title := "netwars.pl - posty użytkownika " + strconv.FormatInt(userID, 10)
+		if len(posts) > 0 {
+			title = "netwars.pl - posty użytkownika " + posts[0].CreatedBy
+		}
+
+		feed := NewPostsFeed("profil/"+strconv.FormatInt(userID, 10), title, netwarsURL+"/profil/"+strconv.FormatInt(userID, 10), posts)
+
+		serveFeed(rw, r, feed, format)
+	}
+}
+
+// serveFeed renders the feed and responds to conditional requests,
+// using ETag computed from the document and Last-Modified taken from the newest entry.
+func serveFeed(rw http.ResponseWriter, r *http.Request, feed *Feed, format string) {
+	var (
+		body []byte
+		err  error
+	)
+
+	feed.Self = requestScheme(r) + "://" + r.Host + r.URL.RequestURI()
+
+	switch format {
+	case feedFormatRSS:
+		rw.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		body, err = feed.RSS()
+	default:
+		rw.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		body, err = feed.Atom()
+	}
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+
+	hash := sha1.Sum(body)
+	rw.Header().Set("ETag", `"`+hex.EncodeToString(hash[:])+`"`)
+
+	http.ServeContent(rw, r, "", feed.Updated, bytes.NewReader(body))
+}
+
+// requestScheme returns scheme used by the client, also behind a proxy that sets X-Forwarded-Proto.
+func requestScheme(r *http.Request) string {
+	if proto := strings.TrimSpace(strings.Split(r.Header.Get("X-Forwarded-Proto"), ",")[0]); proto == "http" || proto == "https" {
+		return proto
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+
+	return "http"
+}
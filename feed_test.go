@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/xml"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPostsFeed(t *testing.T) {
+	created := time.Date(2015, 10, 1, 12, 0, 0, 0, time.UTC)
+	modified := created.Add(time.Hour)
+
+	feed := NewPostsFeed("temat/1", "Zergi", topicLink(1), []*Post{
+		{ID: 11, Serial: 2, TopicID: 1, CreatedAt: &created, CreatedBy: "bob", HTML: "<p>Nie wiem.</p>", Modified: true, ModifiedAt: &modified},
+		{ID: 10, Serial: 1, TopicID: 1, CreatedAt: &created, CreatedBy: "alice", HTML: "<p>Jak grać?</p>"},
+	})
+	assert.Equal(t, modified, feed.Updated)
+
+	b, err := feed.Atom()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var atom atomFeed
+	if assert.NoError(t, xml.Unmarshal(b, &atom)) && assert.Len(t, atom.Entries, 2) {
+		assert.Equal(t, "tag:netwars.pl,2005:temat/1", atom.ID)
+		assert.Equal(t, "2015-10-01T13:00:00Z", atom.Updated)
+		assert.Equal(t, "tag:netwars.pl,2005:temat/1/2", atom.Entries[0].ID)
+		assert.Equal(t, "2015-10-01T12:00:00Z", atom.Entries[0].Published)
+		assert.Equal(t, "2015-10-01T13:00:00Z", atom.Entries[0].Updated)
+		assert.Equal(t, "http://netwars.pl/temat/1#post_11", atom.Entries[0].Links[0].Href)
+		assert.Equal(t, "bob", atom.Entries[0].Author.Name)
+		assert.Equal(t, atomText{Type: "html", Body: "<p>Nie wiem.</p>"}, atom.Entries[0].Content)
+		assert.Equal(t, "2015-10-01T12:00:00Z", atom.Entries[1].Updated)
+	}
+
+	b, err = feed.RSS()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var rss rssDocument
+	if assert.NoError(t, xml.Unmarshal(b, &rss)) && assert.Len(t, rss.Channel.Items, 2) {
+		assert.Equal(t, "Zergi", rss.Channel.Title)
+		assert.Equal(t, "tag:netwars.pl,2005:temat/1/1", rss.Channel.Items[1].GUID.Value)
+		assert.Equal(t, "Thu, 01 Oct 2015 12:00:00 +0000", rss.Channel.Items[1].PubDate)
+		assert.Equal(t, "<p>Jak grać?</p>", rss.Channel.Items[1].Description)
+	}
+}
+
+func TestRequestScheme(t *testing.T) {
+	for expected, r := range map[string]*http.Request{
+		"http":  {Header: http.Header{}},
+		"https": {Header: http.Header{"X-Forwarded-Proto": {"https, http"}}},
+	} {
+		assert.Equal(t, expected, requestScheme(r))
+	}
+
+	assert.Equal(t, "https", requestScheme(&http.Request{Header: http.Header{}, TLS: &tls.ConnectionState{}}))
+}
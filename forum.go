@@ -1,13 +1,101 @@
 package main
 
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
 const (
-	ForumIDStarCraft  = 1
-	ForumIDStarCraft2 = 12
-	ForumIDOtherGames = 3
-	ForumIDOffTopic   = 4
-
-	ForumNameStarCraft  = "StarCraft"
-	ForumNameStarCraft2 = "StarCraft II"
-	ForumNameOtherGames = "Inne Gry"
-	ForumNameOffTopic   = "Off Topic"
+	forumIndexRowSelector         = "table.forum_list tr"
+	forumIndexCategorySelector    = "td.category"
+	forumIndexLinkSelector        = "td.forum a[href^='/forum/']"
+	forumIndexDescriptionSelector = "td.forum .description"
+	forumIndexTopicsSelector      = "td.topics"
+	forumIndexPostsSelector       = "td.posts"
 )
+
+// Forum ...
+type Forum struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Category    string `json:"category"`
+	Topics      int    `json:"topics"`
+	Posts       int    `json:"posts"`
+}
+
+// NewForumsFromDocument parse board index document and returns slice of Forum instances if it is possible.
+// Every forum is assigned to the category that precedes it on the page.
+func NewForumsFromDocument(doc *goquery.Document) (forums []*Forum, err error) {
+	var category string
+
+	doc.Find(forumIndexRowSelector).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if c := s.Find(forumIndexCategorySelector); c.Length() > 0 {
+			category = strings.TrimSpace(c.Text())
+			return true
+		}
+
+		link := s.Find(forumIndexLinkSelector).First()
+		href, exists := link.Attr("href")
+		if !exists {
+			return true
+		}
+
+		var forumID int64
+		forumID, err = strconv.ParseInt(strings.Trim(href[7:], "/"), 10, 32)
+		if err != nil {
+			err = errors.New("malformed forum id in url")
+			return false
+		}
+
+		forum := &Forum{
+			ID:          int(forumID),
+			Name:        strings.TrimSpace(link.Text()),
+			Description: strings.TrimSpace(s.Find(forumIndexDescriptionSelector).Text()),
+			Category:    category,
+		}
+
+		if forum.Topics, err = parseCount(s.Find(forumIndexTopicsSelector).Text()); err != nil {
+			return false
+		}
+		if forum.Posts, err = parseCount(s.Find(forumIndexPostsSelector).Text()); err != nil {
+			return false
+		}
+
+		forums = append(forums, forum)
+
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(forums) == 0 {
+		return nil, errors.New("missing forums in document")
+	}
+
+	return forums, nil
+}
+
+// parseCount parses numbers like "12 345", where spaces are used as thousands separator.
+func parseCount(raw string) (int, error) {
+	raw = strings.Map(func(r rune) rune {
+		if r == ' ' || r == ' ' || r == '\n' || r == '\t' {
+			return -1
+		}
+		return r
+	}, raw)
+	if raw == "" {
+		return 0, nil
+	}
+
+	count, err := strconv.ParseInt(raw, 10, 32)
+	if err != nil {
+		return 0, errors.New("malformed count: " + raw)
+	}
+
+	return int(count), nil
+}
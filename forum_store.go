@@ -0,0 +1,104 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// ForumStoreOpts ...
+type ForumStoreOpts struct {
+	Interval time.Duration
+}
+
+// ForumStore keeps list of forums discovered on the board index and refreshes it periodically.
+type ForumStore struct {
+	sync.RWMutex
+	client   Client
+	err      chan error
+	forums   []*Forum
+	interval time.Duration
+}
+
+// NewForumStore ...
+func NewForumStore(client Client, options ForumStoreOpts) *ForumStore {
+	store := &ForumStore{
+		client:   client,
+		err:      make(chan error, 1),
+		interval: options.Interval,
+	}
+
+	if store.interval > 0 {
+		go store.schedule()
+	}
+
+	return store
+}
+
+// Refresh fetches the board index and replaces list of forums.
+func (fs *ForumStore) Refresh(ctx context.Context) error {
+	forums, err := fs.client.FetchForums(ctx)
+	if err != nil {
+		return err
+	}
+
+	fs.Lock()
+	fs.forums = forums
+	fs.Unlock()
+
+	return nil
+}
+
+// List returns discovered forums. If forums were not fetched yet, it fetches them first.
+func (fs *ForumStore) List(ctx context.Context) ([]*Forum, error) {
+	fs.RLock()
+	forums := fs.forums
+	fs.RUnlock()
+
+	if forums != nil {
+		return forums, nil
+	}
+
+	if err := fs.Refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	fs.RLock()
+	defer fs.RUnlock()
+
+	return fs.forums, nil
+}
+
+// IDs returns identifiers of all discovered forums.
+func (fs *ForumStore) IDs(ctx context.Context) ([]int, error) {
+	forums, err := fs.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, 0, len(forums))
+	for _, forum := range forums {
+		ids = append(ids, forum.ID)
+	}
+
+	return ids, nil
+}
+
+// Err ...
+func (fs *ForumStore) Err() <-chan error {
+	return fs.err
+}
+
+func (fs *ForumStore) schedule() {
+	ticker := time.NewTicker(fs.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := fs.Refresh(context.Background()); err != nil {
+			fs.err <- err
+		}
+
+		<-ticker.C
+	}
+}
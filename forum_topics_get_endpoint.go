@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/piotrkowalczuk/rest"
+	"golang.org/x/net/context"
+)
+
+// ForumTopicsGetEndpoint returns topics of a single forum sorted by date.
+func ForumTopicsGetEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(ForumTopicsGetRequest)
+	if !ok {
+		return nil, rest.InternalServerError(errBadCast, internalServerErrorMessage, 0)
+	}
+
+	storage, err := TopicStorageFromContext(ctx)
+	if err != nil {
+		return nil, rest.InternalServerError(err, internalServerErrorMessage, 0)
+	}
+
+	topics, err := storage.ListByForum(req.ForumID, req.Offset, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTopicsGetResponse(topics), nil
+}
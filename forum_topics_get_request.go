@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/piotrkowalczuk/rest"
+	"golang.org/x/net/context"
+)
+
+// ForumTopicsGetRequest ...
+type ForumTopicsGetRequest struct {
+	ForumID int
+	Offset  int
+	Limit   int
+}
+
+// ForumTopicsGetRequestDecode ...
+func ForumTopicsGetRequestDecode(ctx context.Context, r *http.Request) (interface{}, error) {
+	forumID, err := rest.ParamFromContextInt(ctx, "forumId")
+	if err != nil {
+		return nil, err
+	}
+
+	offset, limit, err := parsePage(r.URL.Query())
+	if err != nil {
+		return nil, err
+	}
+
+	return ForumTopicsGetRequest{
+		ForumID: forumID,
+		Offset:  offset,
+		Limit:   limit,
+	}, nil
+}
@@ -1,21 +1,16 @@
 package main
 
 import (
-	"strconv"
-
+	"github.com/piotrkowalczuk/rest"
 	"golang.org/x/net/context"
 )
 
-var (
-	forumGetResponse = map[string]string{
-		strconv.FormatInt(ForumIDStarCraft, 10):  ForumNameStarCraft,
-		strconv.FormatInt(ForumIDStarCraft2, 10): ForumNameStarCraft2,
-		strconv.FormatInt(ForumIDOtherGames, 10): ForumNameOtherGames,
-		strconv.FormatInt(ForumIDOffTopic, 10):   ForumNameOffTopic,
-	}
-)
-
 // ForumsGetEndpoint returns list of all forums.
 func ForumsGetEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
-	return forumGetResponse, nil
+	storage, err := ForumStorageFromContext(ctx)
+	if err != nil {
+		return nil, rest.InternalServerError(err, internalServerErrorMessage, 0)
+	}
+
+	return storage.List(ctx)
 }
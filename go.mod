@@ -0,0 +1,21 @@
+module github.com/netwars/api
+
+go 1.24
+
+require (
+	github.com/PuerkitoBio/goquery v1.8.1
+	github.com/go-kit/kit v0.13.0
+	github.com/julienschmidt/httprouter v1.3.0
+	github.com/stretchr/testify v1.8.4
+	go.etcd.io/bbolt v1.3.11
+	golang.org/x/net v0.17.0
+)
+
+require (
+	github.com/andybalholm/cascadia v1.3.1 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/stretchr/objx v0.5.1 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"log"
 	"net/http"
 	_ "net/http/pprof"
 	"net/url"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/go-kit/kit/endpoint"
@@ -18,19 +22,55 @@ import (
 )
 
 var (
-	warmUp    int
-	debugAddr string
-	httpAddr  string
+	warmUp            int
+	debugAddr         string
+	httpAddr          string
+	crawlerWorkers    int
+	crawlerRetries    int
+	crawlerBackoff    time.Duration
+	crawlerRPS        float64
+	crawlerPoliteness time.Duration
+	clientTimeout     time.Duration
+	clientUserAgent   string
+	webhookTimeout    time.Duration
+	webhookRetries    int
+	webhookBackoff    time.Duration
+	webhookFailures   int
+	storagePath       string
+	archive           bool
+	archiveRevive     string
+	cacheMaxEntries   int
+	cacheMaxBytes     int64
+	cacheEviction     string
+	refreshMin        time.Duration
+	refreshMax        time.Duration
+	refreshJitter     float64
+	webSocketOrigins  string
 )
 
 const (
 	storageEntryExpiration     = 24 * time.Hour
 	storageEntryInterval       = 30 * time.Second
+	userEntryInterval          = 1 * time.Hour
+	eventsBufferSize           = 1000
+	deletionsLogSize           = 10000
+	forumsRefreshInterval      = 1 * time.Hour
+	crawlerMaxBackoff          = 1 * time.Minute
+	webhookMaxBackoff          = 10 * time.Minute
+	webhookLogSize             = 100
 	internalServerErrorMessage = "Oops... something goes wrong!"
 	netwarsURL                 = "http://netwars.pl"
 )
 
+// errBadCast is returned by endpoints that receive request of unexpected type.
+var errBadCast = errors.New("bad cast")
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		runBackfill(os.Args[2:])
+		return
+	}
+
 	// Flag domain. Note that gRPC transitively registers flags via its import
 	// of glog. So, we define a new flag set, to keep those domains distinct.
 	fs := flag.NewFlagSet("", flag.ExitOnError)
@@ -38,6 +78,21 @@ func main() {
 	fs.IntVar(&warmUp, "warmup", 0, "number of pages per forum to fetch on start")
 	fs.StringVar(&debugAddr, "debug.addr", ":8000", "Address for HTTP debug/instrumentation server")
 	fs.StringVar(&httpAddr, "http.addr", ":8001", "Address for HTTP (JSON) server")
+	fs.StringVar(&storagePath, "storage.path", "", "path of the BoltDB file topics are persisted to, if empty topics are kept only in memory")
+	fs.BoolVar(&archive, "archive", false, "keep expired topics in the storage and serve them as stale instead of removing them")
+	fs.StringVar(&archiveRevive, "archive.revive", "never", "what happens when archived topic is read: never - it is served as it is, read - it is refreshed again")
+	fs.IntVar(&cacheMaxEntries, "cache.maxentries", 0, "maximum number of topics kept in memory, 0 disables the limit")
+	fs.Int64Var(&cacheMaxBytes, "cache.maxbytes", 0, "approximate maximum size of topics kept in memory, in bytes, 0 disables the limit")
+	fs.StringVar(&cacheEviction, "cache.eviction", "lru", "which topics are evicted once cache is full: lru, lfu or tinylfu")
+	fs.DurationVar(&refreshMin, "refresh.min", 5*time.Second, "shortest refresh interval, used for busy topics")
+	fs.DurationVar(&refreshMax, "refresh.max", 1*time.Hour, "longest refresh interval, used for quiet topics, 0 refreshes every topic every 30 seconds")
+	fs.Float64Var(&refreshJitter, "refresh.jitter", 0.1, "fraction by which refresh intervals are randomized")
+	fs.StringVar(&webSocketOrigins, "ws.origins", "", "comma separated origins browsers can open WebSocket connections from, e.g. https://example.com, empty allows any")
+	registerClientFlags(fs)
+	fs.DurationVar(&webhookTimeout, "webhook.timeout", 10*time.Second, "timeout of a single webhook delivery")
+	fs.IntVar(&webhookRetries, "webhook.retries", 5, "number of retries after failed webhook delivery")
+	fs.DurationVar(&webhookBackoff, "webhook.backoff", 5*time.Second, "initial delay between webhook delivery retries, doubled after every attempt")
+	fs.IntVar(&webhookFailures, "webhook.maxfailures", 10, "number of consecutive undelivered events after which webhook is disabled, 0 disables the limit")
 
 	flag.Usage = fs.Usage // only show our flags
 	if err := fs.Parse(os.Args[1:]); err != nil {
@@ -45,37 +100,258 @@ func main() {
 	}
 
 	logger := log.New(os.Stderr, "", log.LstdFlags)
-	u, err := url.Parse(netwarsURL)
+	client, err := newClient()
 	if err != nil {
 		logger.Fatal(err)
 	}
+	crawler := NewCrawler(client, CrawlerOpts{
+		Workers:    crawlerWorkers,
+		Retries:    crawlerRetries,
+		Backoff:    crawlerBackoff,
+		MaxBackoff: crawlerMaxBackoff,
+	})
+	forumStorage := NewForumStore(client, ForumStoreOpts{
+		Interval: forumsRefreshInterval,
+	})
+	go logErrorChannel("forum-storage", forumStorage.Err())
+
+	userCache := cache.NewCache[int](cache.CacheOpts[*User]{
+		Expiration: storageEntryExpiration,
+		Interval:   userEntryInterval,
+	})
+	userStorage := NewUserStore(client, userCache)
+	go logErrorChannel("user-storage", userStorage.Err())
+
+	searchIndex := NewSearchIndex()
+	eventBroker := NewEventBroker(eventsBufferSize)
+	deletionLog := NewDeletionLog(deletionsLogSize)
+
+	eviction, ok := map[string]cache.Eviction{
+		"lru":     cache.EvictLRU,
+		"lfu":     cache.EvictLFU,
+		"tinylfu": cache.EvictTinyLFU,
+	}[cacheEviction]
+	if !ok {
+		logger.Fatalf("unknown cache eviction policy: %s", cacheEviction)
+	}
 
-	client := NewClient(u)
-	topicCache := cache.NewCache(cache.CacheOpts{
+	topicCache := cache.NewCache[int](cache.CacheOpts[*Topic]{
 		Expiration: storageEntryExpiration,
 		Interval:   storageEntryInterval,
+		MaxEntries: cacheMaxEntries,
+		MaxBytes:   cacheMaxBytes,
+		Size:       (*Topic).size,
+		Eviction:   eviction,
 	})
-	topicStorage := NewTopicStore(client, topicCache, TopicStoreOpts{
-		WarmUp: warmUp,
+	var revive RevivePolicy
+	switch archiveRevive {
+	case "never":
+		revive = ReviveNever
+	case "read":
+		revive = ReviveOnRead
+	default:
+		logger.Fatalf("unknown archive revive policy: %s", archiveRevive)
+	}
+
+	var backend TopicBackend
+	if storagePath != "" {
+		bb, err := NewBoltBackend(storagePath)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		// without archive, backfilled topics would be removed as expired
+		backfilled, err := bb.Backfilled()
+		if err != nil {
+			logger.Fatal(err)
+		}
+		if backfilled && !archive {
+			bb.Close()
+			logger.Fatalf("%s contains backfilled topics, it can be used only with -archive", storagePath)
+		}
+
+		backend = bb
+	}
+
+	topicStorage, err := NewTopicStore(client, topicCache, crawler, forumStorage, TopicStoreOpts{
+		WarmUp:         warmUp,
+		Indexers:       []TopicIndexer{userStorage, searchIndex},
+		Events:         eventBroker,
+		Backend:        backend,
+		Archive:        archive,
+		Revive:         revive,
+		Deletions:      deletionLog,
+		MinInterval:    refreshMin,
+		MaxInterval:    refreshMax,
+		IntervalJitter: refreshJitter,
 	})
+	if err != nil {
+		logger.Fatal(err)
+	}
 	go logErrorChannel("topic-storage", topicStorage.Err())
 
-	// Transport: HTTP (debug/instrumentation)
-	go func() {
-		logger.Fatal(http.ListenAndServe(debugAddr, nil))
-	}()
+	if backend != nil {
+		// closing the file waits for pending writes and releases its lock, so the next start does not time out
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-signals
+			if err := backend.Close(); err != nil {
+				logger.Println(err)
+			}
+			os.Exit(0)
+		}()
+	}
+
+	webhookDispatcher := NewWebhookDispatcher(eventBroker, WebhookDispatcherOpts{
+		HTTPClient:  &http.Client{Timeout: webhookTimeout},
+		Retries:     webhookRetries,
+		Backoff:     webhookBackoff,
+		MaxBackoff:  webhookMaxBackoff,
+		MaxFailures: webhookFailures,
+		LogSize:     webhookLogSize,
+	})
+	go logErrorChannel("webhooks", webhookDispatcher.Err())
 
 	ctx := context.Background()
 	ctx = NewTopicStorageContext(ctx, topicStorage)
+	ctx = NewForumStorageContext(ctx, forumStorage)
+	ctx = NewUserStorageContext(ctx, userStorage)
+	ctx = NewSearchIndexContext(ctx, searchIndex)
+	ctx = NewEventBrokerContext(ctx, eventBroker)
+	ctx = NewWebhookDispatcherContext(ctx, webhookDispatcher)
+	ctx = NewDeletionLogContext(ctx, deletionLog)
+
+	// Transport: HTTP (debug/instrumentation), admin API is not exposed publicly
+	http.Handle("/admin/", buildAdminRoutes(ctx))
+	go func() {
+		logger.Fatal(http.ListenAndServe(debugAddr, nil))
+	}()
 
 	logger.Fatal(http.ListenAndServe(httpAddr, buildRoutes(ctx)))
 }
 
+// runBackfill stores every topic of every forum in the storage file. Interrupted run can be resumed.
+func runBackfill(args []string) {
+	var (
+		checkpoint string
+		overwrite  bool
+	)
+
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	fs.StringVar(&storagePath, "storage.path", "topics.db", "path of the BoltDB file topics are stored in")
+	fs.StringVar(&checkpoint, "checkpoint", "", "path of the progress file, defaults to storage path with .checkpoint suffix")
+	fs.BoolVar(&overwrite, "overwrite", false, "fetch topics that are already stored again")
+	registerClientFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		log.Fatal(err)
+	}
+	if checkpoint == "" {
+		checkpoint = storagePath + ".checkpoint"
+	}
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	client, err := newClient()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	backend, err := NewBoltBackend(storagePath)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	defer backend.Close()
+
+	if err := backend.MarkBackfilled(); err != nil {
+		logger.Fatal(err)
+	}
+
+	// progress is saved after every topic, so it is safe to stop at any moment
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		logger.Println("backfill - interrupted, saving progress")
+		cancel()
+	}()
+
+	err = NewBackfill(client, backend, BackfillOpts{
+		Checkpoint: checkpoint,
+		Overwrite:  overwrite,
+		Retries:    crawlerRetries,
+		Backoff:    crawlerBackoff,
+		MaxBackoff: crawlerMaxBackoff,
+	}).Run(ctx)
+	if err != nil {
+		logger.Printf("backfill - stopped: %s", err.Error())
+		return
+	}
+
+	logger.Println("backfill - done")
+}
+
+func registerClientFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&clientTimeout, "client.timeout", 30*time.Second, "timeout of a single request sent to netwars.pl")
+	fs.StringVar(&clientUserAgent, "client.useragent", clientDefaultUserAgent, "User-Agent header sent to netwars.pl")
+	fs.IntVar(&crawlerWorkers, "crawler.workers", 4, "number of topics fetched concurrently")
+	fs.IntVar(&crawlerRetries, "crawler.retries", 3, "number of retries after failed request")
+	fs.DurationVar(&crawlerBackoff, "crawler.backoff", 1*time.Second, "initial delay between retries, doubled after every attempt")
+	fs.Float64Var(&crawlerRPS, "crawler.rps", 5, "maximum number of requests per second sent to netwars.pl")
+	fs.DurationVar(&crawlerPoliteness, "crawler.politeness", 100*time.Millisecond, "minimum delay between requests to the same host")
+}
+
+func newClient() (Client, error) {
+	u, err := url.Parse(netwarsURL)
+	if err != nil {
+		return nil, err
+	}
+
+	client := NewClient(u, ClientOpts{
+		Throttle: NewThrottle(ThrottleOpts{
+			RequestsPerSecond: crawlerRPS,
+			HostDelay:         crawlerPoliteness,
+		}),
+		Timeout:   clientTimeout,
+		UserAgent: clientUserAgent,
+	})
+
+	return client, nil
+}
+
 func buildRoutes(ctx context.Context) *httprouter.Router {
 	router := httprouter.New()
 	router.GET("/topic/:topicId", buildHandler(ctx, TopicGetEndpoint, TopicGetRequestDecode))
+	router.GET("/topic/:topicId/tree", buildHandler(ctx, TopicTreeGetEndpoint, TopicGetRequestDecode))
+	router.GET("/topic/:topicId/feed.atom", TopicFeedHandler(ctx, feedFormatAtom))
+	router.GET("/topic/:topicId/posts/:serial/revisions", buildHandler(ctx, PostRevisionsGetEndpoint, PostRevisionsGetRequestDecode))
 	router.GET("/topics", buildHandler(ctx, TopicsGetEndpoint, TopicsGetRequestDecode))
+	router.GET("/topics.atom", TopicsFeedHandler(ctx, feedFormatAtom))
 	router.GET("/forums", buildHandler(ctx, ForumsGetEndpoint, nil))
+	router.GET("/forums/:forumId/topics", buildHandler(ctx, ForumTopicsGetEndpoint, ForumTopicsGetRequestDecode))
+	router.GET("/forums/:forumId/feed.rss", ForumFeedHandler(ctx, feedFormatRSS))
+	router.GET("/deletions", buildHandler(ctx, DeletionsGetEndpoint, DeletionsGetRequestDecode))
+	router.GET("/events", EventsGetHandler(ctx))
+	router.GET("/ws", WebSocketHandler(ctx, splitList(webSocketOrigins)))
+	router.GET("/search", buildHandler(ctx, SearchGetEndpoint, SearchGetRequestDecode))
+	router.GET("/users/:userId", buildHandler(ctx, UserGetEndpoint, UserGetRequestDecode))
+	router.GET("/users/:userId/posts", buildHandler(ctx, UserPostsGetEndpoint, UserPostsGetRequestDecode))
+	router.GET("/users/:userId/feed.atom", UserFeedHandler(ctx, feedFormatAtom))
+
+	return router
+}
+
+func buildAdminRoutes(ctx context.Context) *httprouter.Router {
+	router := httprouter.New()
+	router.GET("/admin/webhooks", buildHandler(ctx, WebhooksGetEndpoint, nil))
+	router.POST("/admin/webhooks", buildHandler(ctx, WebhookPostEndpoint, WebhookPostRequestDecode))
+	router.GET("/admin/webhooks/:webhookId", buildHandler(ctx, WebhookGetEndpoint, WebhookGetRequestDecode))
+	router.DELETE("/admin/webhooks/:webhookId", buildHandler(ctx, WebhookDeleteEndpoint, WebhookGetRequestDecode))
+	router.POST("/admin/webhooks/:webhookId/enable", buildHandler(ctx, WebhookEnablePostEndpoint, WebhookGetRequestDecode))
+	router.GET("/admin/webhooks/:webhookId/deliveries", buildHandler(ctx, WebhookDeliveriesGetEndpoint, WebhookGetRequestDecode))
+	router.GET("/admin/cache", buildHandler(ctx, CacheGetEndpoint, nil))
+	router.GET("/admin/cache/:topicId", buildHandler(ctx, CacheEntryGetEndpoint, TopicGetRequestDecode))
 
 	return router
 }
@@ -98,19 +374,44 @@ func buildHandler(ctx context.Context, end endpoint.Endpoint, decode rest.Decode
 		After:      []rest.After{},
 		Before:     []rest.Before{},
 		ErrorFunc: func(ctx context.Context, rw http.ResponseWriter, err error) {
-			log.Println(err)
-
-			switch e := err.(type) {
-			case *rest.Error:
-				http.Error(rw, e.Message, e.HTTPCode)
-			default:
-				http.Error(rw, internalServerErrorMessage, http.StatusInternalServerError)
-			}
-
+			writeError(rw, err)
 		},
 	})
 }
 
+// writeError responds with status code that corresponds to the error, unexpected errors are not exposed.
+func writeError(rw http.ResponseWriter, err error) {
+	log.Println(err)
+
+	switch err {
+	case ErrTopicDeleted:
+		http.Error(rw, "topic not found", http.StatusNotFound)
+		return
+	case ErrOffsetOutOfRange:
+		http.Error(rw, "offset out of range", http.StatusBadRequest)
+		return
+	}
+
+	switch e := err.(type) {
+	case *rest.Error:
+		http.Error(rw, e.Message, e.HTTPCode)
+	default:
+		http.Error(rw, internalServerErrorMessage, http.StatusInternalServerError)
+	}
+}
+
+// splitList splits comma separated flag value, skipping empty elements.
+func splitList(list string) []string {
+	var elements []string
+	for _, element := range strings.Split(list, ",") {
+		if element = strings.TrimSpace(element); element != "" {
+			elements = append(elements, element)
+		}
+	}
+
+	return elements
+}
+
 func logErrorChannel(prefix string, err <-chan error) {
 	for e := range err {
 		log.Printf("[%s] - %s", prefix, e.Error())
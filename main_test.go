@@ -6,14 +6,17 @@ import (
 	"testing"
 
 	"encoding/json"
+	"encoding/xml"
 	"time"
 
 	"strconv"
+	"strings"
 
 	"github.com/netwars/api/cache"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"golang.org/x/net/context"
+	"golang.org/x/net/websocket"
 )
 
 func TestTopicGetHandler(t *testing.T) {
@@ -65,31 +68,494 @@ func TestTopicGetHandler(t *testing.T) {
 	}
 }
 
+func TestForumsGetHandler(t *testing.T) {
+	forums := []*Forum{
+		{ID: 1, Name: "StarCraft", Category: "Gry", Topics: 10, Posts: 100},
+		{ID: 12, Name: "StarCraft II", Category: "Gry", Topics: 20, Posts: 200},
+	}
+
+	client := &ClientMock{}
+	client.On("FetchForums").Return(forums, nil)
+	server := setupTestServer(client)
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/forums")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var requestedForums []*Forum
+
+	err = json.NewDecoder(res.Body).Decode(&requestedForums)
+	if assert.NoError(t, err) {
+		assert.Equal(t, forums, requestedForums)
+	}
+}
+
+func TestUserPostsGetHandler(t *testing.T) {
+	now := time.Now()
+	earlier := now.Add(-time.Hour)
+	topic := &Topic{
+		ID:        1,
+		ForumID:   1,
+		Title:     "test",
+		UpdatedAt: &now,
+		Posts: []*Post{
+			{Serial: 1, TopicID: 1, CreatedAt: &earlier, CreatedBy: "alice", CreatedByID: 7},
+			{Serial: 2, TopicID: 1, CreatedAt: &now, CreatedBy: "bob", CreatedByID: 8},
+			{Serial: 3, TopicID: 1, CreatedAt: &now, CreatedBy: "alice", CreatedByID: 7},
+		},
+	}
+
+	client := &ClientMock{}
+	client.On("FetchTopic", 1).Return(topic, nil)
+	server := setupTestServer(client)
+	defer server.Close()
+
+	if _, err := http.Get(server.URL + "/topic/1"); !assert.NoError(t, err) {
+		return
+	}
+
+	res, err := http.Get(server.URL + "/users/7/posts")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var posts []*Post
+
+	err = json.NewDecoder(res.Body).Decode(&posts)
+	if assert.NoError(t, err) && assert.Len(t, posts, 2) {
+		assert.Equal(t, int64(3), posts[0].Serial)
+		assert.Equal(t, int64(1), posts[1].Serial)
+	}
+
+	for _, query := range []string{"offset=-1", "limit=-1", "offset=1&limit=-2", "offset=3"} {
+		res, err = http.Get(server.URL + "/users/7/posts?" + query)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusBadRequest, res.StatusCode, query)
+		}
+	}
+}
+
+func TestForumTopicsGetHandler(t *testing.T) {
+	now := time.Now()
+	client := &ClientMock{}
+	for id, forumID := range map[int]int{1: 1, 2: 4, 3: 1, 4: 1} {
+		updatedAt := now.Add(time.Duration(id) * time.Minute)
+		client.On("FetchTopic", id).Return(&Topic{ID: id, ForumID: forumID, Title: "test", UpdatedAt: &updatedAt}, nil)
+	}
+	server := setupTestServer(client)
+	defer server.Close()
+
+	for id := 1; id <= 4; id++ {
+		if _, err := http.Get(server.URL + "/topic/" + strconv.Itoa(id)); !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	res, err := http.Get(server.URL + "/forums/1/topics?offset=1&limit=5")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var topics []*Topic
+
+	err = json.NewDecoder(res.Body).Decode(&topics)
+	if assert.NoError(t, err) && assert.Len(t, topics, 2) {
+		assert.Equal(t, 3, topics[0].ID)
+		assert.Equal(t, 1, topics[1].ID)
+	}
+
+	for _, path := range []string{"/forums/1/topics?limit=-1", "/forums/1/topics?offset=-1", "/topics?limit=-1", "/topics?offset=-5&limit=2"} {
+		res, err = http.Get(server.URL + path)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusBadRequest, res.StatusCode, path)
+		}
+	}
+}
+
+func TestSearchGetHandler(t *testing.T) {
+	now := time.Now()
+	client := &ClientMock{}
+	client.On("FetchTopic", 1).Return(&Topic{ID: 1, ForumID: 12, Title: "Zergi", UpdatedAt: &now, Posts: []*Post{
+		{Serial: 1, TopicID: 1, CreatedAt: &now, CreatedBy: "alice", Content: "Jak grać przeciwko zergom?"},
+		{Serial: 2, TopicID: 1, CreatedAt: &now, CreatedBy: "bob", Content: "Nie wiem."},
+	}}, nil)
+	server := setupTestServer(client)
+	defer server.Close()
+
+	if _, err := http.Get(server.URL + "/topic/1"); !assert.NoError(t, err) {
+		return
+	}
+
+	res, err := http.Get(server.URL + "/search?q=zerg&author=alice")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var results []map[string]interface{}
+
+	err = json.NewDecoder(res.Body).Decode(&results)
+	if assert.NoError(t, err) && assert.Len(t, results, 1) {
+		assert.Equal(t, float64(1), results[0]["serial"])
+		assert.Equal(t, "Zergi", results[0]["title"])
+		assert.Equal(t, "Jak grać przeciwko <em>zergom?</em>", results[0]["snippet"])
+	}
+
+	// plain date includes the whole day
+	res, err = http.Get(server.URL + "/search?q=zerg&author=alice&to=" + now.UTC().Format(searchDateLayout))
+	if assert.NoError(t, err) {
+		results = nil
+		if assert.NoError(t, json.NewDecoder(res.Body).Decode(&results)) {
+			assert.Len(t, results, 1)
+		}
+	}
+
+	// zero limit gives an empty page, like in other lists
+	res, err = http.Get(server.URL + "/search?q=zerg&limit=0")
+	if assert.NoError(t, err) {
+		results = nil
+		if assert.NoError(t, json.NewDecoder(res.Body).Decode(&results)) {
+			assert.Len(t, results, 0)
+		}
+	}
+
+	for _, path := range []string{"/search", "/search?q=zerg&offset=-1", "/search?q=zerg&limit=-1"} {
+		res, err = http.Get(server.URL + path)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusBadRequest, res.StatusCode, path)
+		}
+	}
+}
+
+func TestWebSocketHandler(t *testing.T) {
+	now := time.Now()
+	client := &ClientMock{}
+	client.On("FetchTopic", 1).Return(&Topic{ID: 1, ForumID: 12, Title: "Zergi", UpdatedAt: &now, Posts: []*Post{
+		{Serial: 1, TopicID: 1, CreatedAt: &now, CreatedBy: "alice", Content: "Jak grać przeciwko zergom?"},
+	}}, nil).Once()
+	ctx := setupTestContext(client)
+	server := httptest.NewServer(buildRoutes(ctx))
+	defer server.Close()
+
+	ws, err := websocket.Dial("ws"+server.URL[len("http"):]+"/ws", "", server.URL)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ws.Close()
+
+	var msg WebSocketMessage
+
+	exchange := func(req *WebSocketRequest) bool {
+		msg = WebSocketMessage{}
+		return assert.NoError(t, websocket.JSON.Send(ws, req)) && assert.NoError(t, websocket.JSON.Receive(ws, &msg))
+	}
+
+	if exchange(&WebSocketRequest{Action: WebSocketActionSubscribe}) {
+		assert.Equal(t, WebSocketMessageError, msg.Type)
+	}
+	if exchange(&WebSocketRequest{Action: WebSocketActionSubscribe, TopicID: 1}) {
+		assert.Equal(t, WebSocketMessageSubscribed, msg.Type)
+	}
+	if exchange(&WebSocketRequest{Action: WebSocketActionSubscribe, Author: "Bob"}) {
+		assert.Equal(t, WebSocketMessageSubscribed, msg.Type)
+	}
+
+	broker, err := EventBrokerFromContext(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	broker.Publish(&Event{Type: EventPostCreated, TopicID: 2, ForumID: 12, Post: &Post{Serial: 1, CreatedBy: "alice"}})
+	broker.Publish(&Event{Type: EventPostCreated, TopicID: 3, ForumID: 12, Post: &Post{Serial: 1, CreatedBy: "bob"}})
+	broker.Publish(&Event{Type: EventPostCreated, TopicID: 1, ForumID: 12, Post: &Post{Serial: 2, CreatedBy: "alice"}})
+
+	for _, topicID := range []int{3, 1} {
+		msg = WebSocketMessage{}
+		if assert.NoError(t, websocket.JSON.Receive(ws, &msg)) && assert.Equal(t, WebSocketMessageEvent, msg.Type) {
+			assert.Equal(t, topicID, msg.Event.TopicID)
+		}
+	}
+
+	if exchange(&WebSocketRequest{Action: WebSocketActionUnsubscribe, TopicID: 1}) {
+		assert.Equal(t, WebSocketMessageUnsubscribed, msg.Type)
+	}
+
+	// deleted topic is served, but it is not refreshed, so it cannot be followed
+	storage, err := TopicStorageFromContext(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	storage.addTombstone(&Topic{ID: 5, ForumID: 12, Title: "Protosi", UpdatedAt: &now, DeletedAt: &now})
+	if exchange(&WebSocketRequest{Action: WebSocketActionSubscribe, TopicID: 5}) {
+		assert.Equal(t, WebSocketMessageError, msg.Type)
+		assert.Equal(t, errWebSocketNotRefreshed.Error(), msg.Message)
+	}
+	client.AssertExpectations(t)
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	handshake := webSocketHandshake([]string{"https://example.com/"})
+
+	for origin, allowed := range map[string]bool{
+		"":                     true,
+		"https://example.com":  true,
+		"https://EXAMPLE.com":  true,
+		"http://example.com":   false,
+		"https://evil.example": false,
+	} {
+		r := httptest.NewRequest("GET", "/ws", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+
+		err := handshake(&websocket.Config{Version: websocket.ProtocolVersionHybi13}, r)
+		assert.Equal(t, allowed, err == nil, origin)
+	}
+
+	assert.NoError(t, webSocketHandshake(nil)(&websocket.Config{Version: websocket.ProtocolVersionHybi13}, httptest.NewRequest("GET", "/ws", nil)))
+}
+
+func TestTopicFeedHandler(t *testing.T) {
+	now := time.Date(2015, 10, 1, 12, 0, 0, 0, time.UTC)
+	client := &ClientMock{}
+	client.On("FetchTopic", 1).Return(&Topic{ID: 1, ForumID: 12, Title: "Zergi", UpdatedAt: &now, Posts: []*Post{
+		{Serial: 1, TopicID: 1, CreatedAt: &now, CreatedBy: "alice", HTML: "Jak grać?"},
+	}}, nil).Once()
+	client.On("FetchForums").Return([]*Forum{{ID: 12, Name: "StarCraft"}}, nil).Once()
+	server := setupTestServer(client)
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/topic/1/feed.atom")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "application/atom+xml; charset=utf-8", res.Header.Get("Content-Type"))
+	assert.Equal(t, now.Format(http.TimeFormat), res.Header.Get("Last-Modified"))
+
+	etag := res.Header.Get("ETag")
+	if !assert.NotEmpty(t, etag) {
+		return
+	}
+
+	for header, value := range map[string]string{
+		"If-None-Match":     etag,
+		"If-Modified-Since": now.Format(http.TimeFormat),
+	} {
+		req, _ := http.NewRequest("GET", server.URL+"/topic/1/feed.atom", nil)
+		req.Header.Set(header, value)
+
+		res, err := http.DefaultClient.Do(req)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusNotModified, res.StatusCode, header)
+		}
+	}
+
+	res, err = http.Get(server.URL + "/forums/12/feed.rss")
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "application/rss+xml; charset=utf-8", res.Header.Get("Content-Type"))
+
+		var rss rssDocument
+		if assert.NoError(t, xml.NewDecoder(res.Body).Decode(&rss)) && assert.Len(t, rss.Channel.Items, 1) {
+			assert.Equal(t, "netwars.pl - StarCraft", rss.Channel.Title)
+			assert.Equal(t, "Zergi", rss.Channel.Items[0].Title)
+		}
+	}
+	client.AssertExpectations(t)
+}
+
+func TestPostRevisionsGetHandler(t *testing.T) {
+	now := time.Now()
+	client := &ClientMock{}
+	client.On("FetchTopic", 1).Return(&Topic{ID: 1, ForumID: 12, Title: "Zergi", UpdatedAt: &now, Posts: []*Post{
+		{Serial: 1, TopicID: 1, CreatedAt: &now, CreatedBy: "alice", HTML: "Jak grać?", Markdown: "Jak grać?\nPomocy"},
+	}}, nil).Once()
+	ctx := setupTestContext(client)
+	server := httptest.NewServer(buildRoutes(ctx))
+	defer server.Close()
+
+	storage, err := TopicStorageFromContext(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	if _, err := storage.GetOrRetrieve(ctx, 1); !assert.NoError(t, err) {
+		return
+	}
+
+	// refresh that found edited post
+	storage.Set(&Topic{ID: 1, ForumID: 12, Title: "Zergi", UpdatedAt: &now, Posts: []*Post{
+		{Serial: 1, TopicID: 1, CreatedAt: &now, CreatedBy: "alice", HTML: "Jak grać z zergami?", Markdown: "Jak grać z zergami?\nPomocy", Modified: true, ModifiedAt: &now, ModifiedBy: "alice"},
+	}})
+
+	res, err := http.Get(server.URL + "/topic/1/posts/1/revisions")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var revisions PostRevisionsGetResponse
+	if assert.NoError(t, json.NewDecoder(res.Body).Decode(&revisions)) && assert.Len(t, revisions.Revisions, 2) {
+		assert.False(t, revisions.Truncated)
+		assert.Equal(t, 1, revisions.Revisions[0].Version)
+		assert.Equal(t, "Jak grać?", revisions.Revisions[0].HTML)
+		assert.Empty(t, revisions.Revisions[0].Diff)
+		assert.Equal(t, 2, revisions.Revisions[1].Version)
+		assert.Equal(t, "alice", revisions.Revisions[1].ModifiedBy)
+		assert.Equal(t, "--- v1\n+++ v2\n@@ -1,2 +1,2 @@\n-Jak grać?\n+Jak grać z zergami?\n Pomocy\n", revisions.Revisions[1].Diff)
+	}
+
+	res, err = http.Get(server.URL + "/topic/1/posts/2/revisions")
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	}
+}
+
+func TestWebhookHandlers(t *testing.T) {
+	server := httptest.NewServer(buildAdminRoutes(setupTestContext(&ClientMock{})))
+	defer server.Close()
+
+	res, err := http.Post(server.URL+"/admin/webhooks", "application/json", strings.NewReader(`{"url":"ftp://example.com"}`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	}
+
+	res, err = http.Post(server.URL+"/admin/webhooks", "application/json", strings.NewReader(`{"url":"http://example.com/hook","authors":["alice"]}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var webhook Webhook
+	if assert.NoError(t, json.NewDecoder(res.Body).Decode(&webhook)) {
+		assert.NotEmpty(t, webhook.Secret)
+		assert.Equal(t, []string{"alice"}, webhook.Authors)
+	}
+
+	res, err = http.Get(server.URL + "/admin/webhooks/" + strconv.Itoa(webhook.ID))
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, res.StatusCode) {
+		webhook = Webhook{}
+		if assert.NoError(t, json.NewDecoder(res.Body).Decode(&webhook)) {
+			assert.Empty(t, webhook.Secret)
+		}
+	}
+
+	res, err = http.Get(server.URL + "/admin/webhooks/1000/deliveries")
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	}
+}
+
+func TestCacheHandlers(t *testing.T) {
+	ctx := setupTestContext(&ClientMock{})
+	server := httptest.NewServer(buildAdminRoutes(ctx))
+	defer server.Close()
+
+	storage, _ := TopicStorageFromContext(ctx)
+	now := time.Now()
+	storage.Set(&Topic{ID: 1, ForumID: 12, Title: "Zergi", UpdatedAt: &now})
+	storage.GetOrRetrieve(ctx, 1)
+
+	res, err := http.Get(server.URL + "/admin/cache")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var stats CacheGetResponse
+	if assert.NoError(t, json.NewDecoder(res.Body).Decode(&stats)) {
+		assert.Equal(t, 1, stats.Topics.Entries)
+		assert.Equal(t, uint64(1), stats.Topics.Hits)
+		assert.Equal(t, 1.0, stats.Topics.HitRatio)
+		assert.Equal(t, 1, stats.Topics.Ages[0].Entries)
+		assert.Zero(t, stats.Users.Entries)
+	}
+
+	res, err = http.Get(server.URL + "/admin/cache/1")
+	if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, res.StatusCode) {
+		var entry CacheEntryGetResponse
+		if assert.NoError(t, json.NewDecoder(res.Body).Decode(&entry)) {
+			assert.Equal(t, "Zergi", entry.Title)
+			assert.Equal(t, uint64(1), entry.Hits)
+			assert.True(t, entry.NextRefreshAt.After(entry.SetAt))
+		}
+	}
+
+	res, err = http.Get(server.URL + "/admin/cache/2")
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	}
+}
+
 func setupTestServer(client Client) *httptest.Server {
-	topicCache := cache.NewCache(cache.CacheOpts{
+	return httptest.NewServer(buildRoutes(setupTestContext(client)))
+}
+
+func setupTestContext(client Client) context.Context {
+	forumStorage := NewForumStore(client, ForumStoreOpts{})
+	userStorage := NewUserStore(client, cache.NewCache[int](cache.CacheOpts[*User]{
+		Expiration: 1000000 * time.Hour,
+		Interval:   1000000 * time.Hour,
+	}))
+	topicCache := cache.NewCache[int](cache.CacheOpts[*Topic]{
 		Expiration: 1000000 * time.Hour,
 		Interval:   1000000 * time.Hour,
 	})
-	topicStorage := NewTopicStore(client, topicCache, TopicStoreOpts{
-		WarmUp: warmUp,
+	crawler := NewCrawler(client, CrawlerOpts{})
+	searchIndex := NewSearchIndex()
+	eventBroker := NewEventBroker(100)
+	deletionLog := NewDeletionLog(100)
+	topicStorage, _ := NewTopicStore(client, topicCache, crawler, forumStorage, TopicStoreOpts{
+		WarmUp:    warmUp,
+		Indexers:  []TopicIndexer{userStorage, searchIndex},
+		Events:    eventBroker,
+		Deletions: deletionLog,
 	})
 	go logErrorChannel("topic-storage", topicStorage.Err())
 
 	ctx := context.Background()
 	ctx = NewTopicStorageContext(ctx, topicStorage)
+	ctx = NewForumStorageContext(ctx, forumStorage)
+	ctx = NewUserStorageContext(ctx, userStorage)
+	ctx = NewSearchIndexContext(ctx, searchIndex)
+	ctx = NewEventBrokerContext(ctx, eventBroker)
+	ctx = NewWebhookDispatcherContext(ctx, NewWebhookDispatcher(eventBroker, WebhookDispatcherOpts{}))
+	ctx = NewDeletionLogContext(ctx, deletionLog)
 
-	return httptest.NewServer(buildRoutes(ctx))
+	return ctx
 }
 
 type ClientMock struct {
 	mock.Mock
 }
 
-func (cm *ClientMock) FetchTopic(id int) (*Topic, error) {
+func (cm *ClientMock) FetchForums(context.Context) ([]*Forum, error) {
+	args := cm.Called()
+	return args.Get(0).([]*Forum), args.Error(1)
+}
+
+func (cm *ClientMock) FetchForumPages(_ context.Context, id int) (int, error) {
 	args := cm.Called(id)
-	return args.Get(0).(*Topic), args.Error(1)
+	return args.Int(0), args.Error(1)
 }
 
-func (cm *ClientMock) FetchTopics(int, chan<- *Topic) error {
-	return nil
+func (cm *ClientMock) FetchTopicsForForum(_ context.Context, id, page int) ([]*Topic, error) {
+	args := cm.Called(id, page)
+	topics, _ := args.Get(0).([]*Topic)
+	return topics, args.Error(1)
+}
+
+func (cm *ClientMock) FetchTopic(_ context.Context, id int) (*Topic, error) {
+	args := cm.Called(id)
+	topic, _ := args.Get(0).(*Topic)
+	return topic, args.Error(1)
+}
+
+func (cm *ClientMock) RefreshTopic(_ context.Context, prev *Topic) (*Topic, error) {
+	args := cm.Called(prev)
+	topic, _ := args.Get(0).(*Topic)
+	return topic, args.Error(1)
+}
+
+func (cm *ClientMock) FetchUser(_ context.Context, id int) (*User, error) {
+	args := cm.Called(id)
+	user, _ := args.Get(0).(*User)
+	return user, args.Error(1)
 }
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"errors"
 	"strings"
 	"time"
 
@@ -16,32 +15,44 @@ const (
 
 // Post ...
 type Post struct {
-	Serial     int64      `json:"serial"`
-	TopicID    int64      `json:"topicId"`
-	CreatedAt  *time.Time `json:"createdAt"`
-	CreatedBy  string     `json:"createdBy"`
-	Modified   bool       `json:"modified"`
-	ModifiedAt *time.Time `json:"modifiedAt"`
-	ModifiedBy string     `json:"modifiedBy"`
-	Content    string     `json:"content"`
+	ID          int64        `json:"id"`
+	Serial      int64        `json:"serial"`
+	TopicID     int64        `json:"topicId"`
+	CreatedAt   *time.Time   `json:"createdAt"`
+	CreatedBy   string       `json:"createdBy"`
+	CreatedByID int          `json:"createdById"`
+	Modified    bool         `json:"modified"`
+	ModifiedAt  *time.Time   `json:"modifiedAt"`
+	ModifiedBy  string       `json:"modifiedBy"`
+	Content     string       `json:"content"`
+	HTML        string       `json:"html"`
+	Markdown    string       `json:"markdown"`
+	Blocks      []*PostBlock `json:"blocks"`
+	ReplyTo     []int64      `json:"replyTo"`
+	RepliedBy   []int64      `json:"repliedBy"`
+	// DeletedAt is set for posts that disappeared from the topic, the last seen version is kept.
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+
+	revisions []*PostRevision
 }
 
 // NewTopicFromDocument parse given document to find matching patterns and returns slice of Post instances if it is possible.
 func NewPostsFromDocument(doc *goquery.Document) (posts []*Post, err error) {
-	parts := strings.Split(doc.Url.String(), "/")
-	if len(parts) < 3 || parts[len(parts)-2] != "temat" {
-		return nil, errors.New("malformed topic url")
-	}
-
-	topicID, err := strconv.ParseInt(parts[len(parts)-1], 10, 64)
+	topicID, err := topicIDFromURL(doc.Url)
 	if err != nil {
-		return nil, errors.New("malformed topic id in url")
+		return nil, err
 	}
 
 	doc.Find("div.post[id^='post_']").EachWithBreak(func(i int, s *goquery.Selection) bool {
 		var createdAt *time.Time
 		var modifiedAt *time.Time
-		var serial int64
+		var id, serial int64
+
+		anchor, _ := s.Attr("id")
+		id, err = strconv.ParseInt(anchor[5:], 10, 64)
+		if err != nil {
+			return false
+		}
 
 		serialText := s.Find("span.numerek_posta").Text()
 		serial, err = strconv.ParseInt(serialText[2:len(serialText)-1], 10, 64)
@@ -54,12 +65,27 @@ func NewPostsFromDocument(doc *goquery.Document) (posts []*Post, err error) {
 			return false
 		}
 
+		// structured content has to be built before cleanup, which removes quotes
+		body := s.Find("div.post_body")
+		content := NewPostContentFromSelection(body, doc.Url)
+
+		nick := s.Find("div.p2_nick a.nick")
+
 		post := &Post{
+			ID:        id,
 			TopicID:   topicID,
 			Serial:    serial,
-			Content:   cleanupPostContent(s.Find("div.post_body")).Text(),
+			Content:   cleanupPostContent(body).Text(),
+			HTML:      content.HTML,
+			Markdown:  content.Markdown,
+			Blocks:    content.Blocks,
 			CreatedAt: createdAt,
-			CreatedBy: s.Find("div.p2_nick a.nick").Text(),
+			CreatedBy: nick.Text(),
+		}
+
+		// guests and removed accounts do not link to profile
+		if href, ok := nick.Attr("href"); ok {
+			post.CreatedByID, _ = userIDFromHref(href)
 		}
 
 		if mod := s.Find(postModificationSelector).Text(); mod != "" {
@@ -92,8 +118,25 @@ func (p Post) String() string {
 	return strconv.FormatInt(p.TopicID, 10) + ":" + strconv.FormatInt(p.Serial, 10) + " - " + p.CreatedAt.String() + " " + p.CreatedBy
 }
 
+type postsBySerial []*Post
+
+// Len implements sort.Interface.
+func (p postsBySerial) Len() int {
+	return len(p)
+}
+
+// Swap implements sort.Interface.
+func (p postsBySerial) Swap(i, j int) {
+	p[i], p[j] = p[j], p[i]
+}
+
+// Less implements sort.Interface.
+func (p postsBySerial) Less(i, j int) bool {
+	return p[i].Serial < p[j].Serial
+}
+
 func cleanupPostContent(s *goquery.Selection) *goquery.Selection {
-	s.RemoveFiltered("div.cite")
+	s.RemoveFiltered(postQuoteSelector)
 	s.Find("br").ReplaceWithHtml("\n")
 
 	return s
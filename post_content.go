@@ -0,0 +1,508 @@
+package main
+
+import (
+	"bytes"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	postQuoteSelector       = "div.cite"
+	postQuoteHeaderSelector = "div.cite_head"
+	postSpoilerClass        = "spoiler"
+	postCodeClass           = "code"
+	postQuoteClass          = "cite"
+	postQuoteHeaderClass    = "cite_head"
+	postQuoteAuthorSuffix   = " napisał"
+)
+
+// Types of PostBlock.
+const (
+	PostBlockText    = "text"
+	PostBlockQuote   = "quote"
+	PostBlockLink    = "link"
+	PostBlockImage   = "image"
+	PostBlockVideo   = "video"
+	PostBlockCode    = "code"
+	PostBlockSpoiler = "spoiler"
+)
+
+var (
+	// postVideoHosts lists hosts that embedded players are accepted from.
+	postVideoHosts = []string{"youtube.com", "youtube-nocookie.com", "youtu.be", "vimeo.com", "twitch.tv", "dailymotion.com"}
+	// postAllowedTags maps tags that survive sanitization to attributes they may keep.
+	postAllowedTags = map[atom.Atom][]string{
+		atom.A:          {"href"},
+		atom.B:          nil,
+		atom.Blockquote: nil,
+		atom.Br:         nil,
+		atom.Code:       nil,
+		atom.Del:        nil,
+		atom.Em:         nil,
+		atom.I:          nil,
+		atom.Img:        {"src", "alt"},
+		atom.Li:         nil,
+		atom.Ol:         nil,
+		atom.P:          nil,
+		atom.Pre:        nil,
+		atom.S:          nil,
+		atom.Strong:     nil,
+		atom.U:          nil,
+		atom.Ul:         nil,
+	}
+	// postDroppedTags are removed together with their content.
+	postDroppedTags = map[atom.Atom]bool{
+		atom.Script:   true,
+		atom.Style:    true,
+		atom.Form:     true,
+		atom.Textarea: true,
+		atom.Select:   true,
+		atom.Button:   true,
+	}
+)
+
+// PostBlock is a single structured part of post content.
+// Quote and spoiler blocks contain nested blocks with their content.
+type PostBlock struct {
+	Type   string       `json:"type"`
+	Text   string       `json:"text,omitempty"`
+	URL    string       `json:"url,omitempty"`
+	Author string       `json:"author,omitempty"`
+	Serial int64        `json:"serial,omitempty"`
+	PostID int64        `json:"postId,omitempty"`
+	Blocks []*PostBlock `json:"blocks,omitempty"`
+}
+
+// PostContent holds post body in formats that allow API clients to render it faithfully.
+type PostContent struct {
+	HTML     string
+	Markdown string
+	Blocks   []*PostBlock
+}
+
+// NewPostContentFromSelection converts post body into sanitized HTML, Markdown and list of blocks.
+// Relative links are resolved against base url.
+func NewPostContentFromSelection(s *goquery.Selection, base *url.URL) *PostContent {
+	pc := &postContentParser{base: base}
+
+	var h, md bytes.Buffer
+	var blocks []*PostBlock
+
+	for _, node := range s.Nodes {
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			pc.writeHTML(&h, c)
+			pc.writeMarkdown(&md, c, "")
+		}
+		blocks = append(blocks, pc.blocks(node)...)
+	}
+
+	return &PostContent{
+		HTML:     strings.TrimSpace(h.String()),
+		Markdown: strings.TrimSpace(collapseNewLines(md.String())),
+		Blocks:   blocks,
+	}
+}
+
+type postContentParser struct {
+	base *url.URL
+}
+
+// resolve returns absolute url if given reference is safe to expose, empty string otherwise.
+func (pc *postContentParser) resolve(ref string) string {
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return ""
+	}
+	if pc.base != nil {
+		u = pc.base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+
+	return u.String()
+}
+
+// video returns url of embedded player if the node is one that comes from trusted host.
+func (pc *postContentParser) video(n *html.Node) string {
+	var src string
+
+	switch n.DataAtom {
+	case atom.Iframe, atom.Embed:
+		src = attr(n, "src")
+	case atom.Object:
+		src = attr(n, "data")
+	default:
+		return ""
+	}
+
+	src = pc.resolve(src)
+	if src == "" {
+		return ""
+	}
+
+	u, _ := url.Parse(src)
+	host := strings.TrimPrefix(u.Host, "www.")
+	for _, h := range postVideoHosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return src
+		}
+	}
+
+	return ""
+}
+
+func (pc *postContentParser) writeHTML(buf *bytes.Buffer, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		buf.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if postDroppedTags[n.DataAtom] {
+		return
+	}
+
+	switch {
+	case hasClass(n, postQuoteClass):
+		pc.writeHTMLElement(buf, n, "blockquote", nil)
+		return
+	case hasClass(n, postSpoilerClass):
+		buf.WriteString("<details><summary>Spoiler</summary>")
+		pc.writeHTMLChildren(buf, n)
+		buf.WriteString("</details>")
+		return
+	case hasClass(n, postCodeClass):
+		buf.WriteString("<pre><code>")
+		buf.WriteString(html.EscapeString(nodeText(n)))
+		buf.WriteString("</code></pre>")
+		return
+	}
+
+	if src := pc.video(n); src != "" {
+		buf.WriteString(`<iframe src="` + html.EscapeString(src) + `" allowfullscreen></iframe>`)
+		return
+	}
+
+	allowed, ok := postAllowedTags[n.DataAtom]
+	if !ok {
+		// unknown tags are unwrapped, their content stays
+		pc.writeHTMLChildren(buf, n)
+		return
+	}
+
+	attrs := make([]html.Attribute, 0, len(allowed))
+	for _, name := range allowed {
+		value := attr(n, name)
+		if name == "href" || name == "src" {
+			value = pc.resolve(value)
+		}
+		if value != "" {
+			attrs = append(attrs, html.Attribute{Key: name, Val: value})
+		}
+	}
+
+	if n.DataAtom == atom.Img && len(attrs) == 0 {
+		return
+	}
+
+	pc.writeHTMLElement(buf, n, n.Data, attrs)
+}
+
+func (pc *postContentParser) writeHTMLElement(buf *bytes.Buffer, n *html.Node, tag string, attrs []html.Attribute) {
+	buf.WriteString("<" + tag)
+	for _, a := range attrs {
+		buf.WriteString(" " + a.Key + `="` + html.EscapeString(a.Val) + `"`)
+	}
+	buf.WriteString(">")
+
+	if n.DataAtom == atom.Br || n.DataAtom == atom.Img {
+		return
+	}
+
+	pc.writeHTMLChildren(buf, n)
+	buf.WriteString("</" + tag + ">")
+}
+
+func (pc *postContentParser) writeHTMLChildren(buf *bytes.Buffer, n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		pc.writeHTML(buf, c)
+	}
+}
+
+func (pc *postContentParser) writeMarkdown(buf *bytes.Buffer, n *html.Node, prefix string) {
+	switch n.Type {
+	case html.TextNode:
+		buf.WriteString(strings.Replace(n.Data, "\n", "\n"+prefix, -1))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if postDroppedTags[n.DataAtom] {
+		return
+	}
+
+	switch {
+	case hasClass(n, postQuoteClass):
+		buf.WriteString("\n\n" + prefix + "> ")
+		pc.writeMarkdownChildren(buf, n, prefix+"> ")
+		buf.WriteString("\n\n" + prefix)
+		return
+	case hasClass(n, postQuoteHeaderClass):
+		buf.WriteString("**" + strings.TrimSpace(nodeText(n)) + "**\n" + prefix)
+		return
+	case hasClass(n, postSpoilerClass):
+		buf.WriteString("\n\n" + prefix + "> **Spoiler:** ")
+		pc.writeMarkdownChildren(buf, n, prefix+"> ")
+		buf.WriteString("\n\n" + prefix)
+		return
+	case hasClass(n, postCodeClass) || n.DataAtom == atom.Pre:
+		buf.WriteString("\n\n" + prefix + "```\n" + prefix)
+		buf.WriteString(strings.Replace(strings.Trim(nodeText(n), "\n"), "\n", "\n"+prefix, -1))
+		buf.WriteString("\n" + prefix + "```\n\n" + prefix)
+		return
+	}
+
+	if src := pc.video(n); src != "" {
+		buf.WriteString("[video](" + src + ")")
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		buf.WriteString("\n" + prefix)
+	case atom.B, atom.Strong:
+		pc.writeMarkdownWrapped(buf, n, prefix, "**")
+	case atom.I, atom.Em:
+		pc.writeMarkdownWrapped(buf, n, prefix, "_")
+	case atom.S, atom.Del:
+		pc.writeMarkdownWrapped(buf, n, prefix, "~~")
+	case atom.Code:
+		pc.writeMarkdownWrapped(buf, n, prefix, "`")
+	case atom.A:
+		href := pc.resolve(attr(n, "href"))
+		if href == "" {
+			pc.writeMarkdownChildren(buf, n, prefix)
+			return
+		}
+		buf.WriteString("[")
+		pc.writeMarkdownChildren(buf, n, prefix)
+		buf.WriteString("](" + href + ")")
+	case atom.Img:
+		if src := pc.resolve(attr(n, "src")); src != "" {
+			buf.WriteString("![" + attr(n, "alt") + "](" + src + ")")
+		}
+	case atom.Li:
+		buf.WriteString("\n" + prefix + "- ")
+		pc.writeMarkdownChildren(buf, n, prefix)
+	case atom.P, atom.Div, atom.Ul, atom.Ol, atom.Blockquote:
+		buf.WriteString("\n\n" + prefix)
+		pc.writeMarkdownChildren(buf, n, prefix)
+		buf.WriteString("\n\n" + prefix)
+	default:
+		pc.writeMarkdownChildren(buf, n, prefix)
+	}
+}
+
+func (pc *postContentParser) writeMarkdownWrapped(buf *bytes.Buffer, n *html.Node, prefix, wrap string) {
+	buf.WriteString(wrap)
+	pc.writeMarkdownChildren(buf, n, prefix)
+	buf.WriteString(wrap)
+}
+
+func (pc *postContentParser) writeMarkdownChildren(buf *bytes.Buffer, n *html.Node, prefix string) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		pc.writeMarkdown(buf, c, prefix)
+	}
+}
+
+// blocks splits children of the node into blocks. Formatting tags are transparent,
+// text around links, images and other special elements is gathered into text blocks.
+func (pc *postContentParser) blocks(n *html.Node) []*PostBlock {
+	var blocks []*PostBlock
+	var text bytes.Buffer
+
+	flush := func() {
+		if t := strings.TrimSpace(collapseNewLines(text.String())); t != "" {
+			blocks = append(blocks, &PostBlock{Type: PostBlockText, Text: t})
+		}
+		text.Reset()
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			switch c.Type {
+			case html.TextNode:
+				text.WriteString(c.Data)
+				continue
+			case html.ElementNode:
+			default:
+				continue
+			}
+
+			if postDroppedTags[c.DataAtom] || hasClass(c, postQuoteHeaderClass) {
+				continue
+			}
+
+			var block *PostBlock
+
+			switch {
+			case hasClass(c, postQuoteClass):
+				block = pc.quote(c)
+			case hasClass(c, postSpoilerClass):
+				block = &PostBlock{Type: PostBlockSpoiler, Text: strings.TrimSpace(nodeText(c)), Blocks: pc.blocks(c)}
+			case hasClass(c, postCodeClass) || c.DataAtom == atom.Pre:
+				block = &PostBlock{Type: PostBlockCode, Text: strings.Trim(nodeText(c), "\n")}
+			}
+
+			if block == nil {
+				if src := pc.video(c); src != "" {
+					block = &PostBlock{Type: PostBlockVideo, URL: src}
+				}
+			}
+
+			if block == nil {
+				switch c.DataAtom {
+				case atom.Br:
+					text.WriteString("\n")
+					continue
+				case atom.A:
+					if href := pc.resolve(attr(c, "href")); href != "" {
+						block = &PostBlock{Type: PostBlockLink, URL: href, Text: strings.TrimSpace(nodeText(c))}
+					}
+				case atom.Img:
+					if src := pc.resolve(attr(c, "src")); src != "" {
+						block = &PostBlock{Type: PostBlockImage, URL: src, Text: attr(c, "alt")}
+					}
+				}
+			}
+
+			if block == nil {
+				walk(c)
+				continue
+			}
+
+			flush()
+			blocks = append(blocks, block)
+		}
+	}
+
+	walk(n)
+	flush()
+
+	return blocks
+}
+
+// quote builds quote block. Header of the quote (if any) names quoted author and links to quoted post.
+func (pc *postContentParser) quote(n *html.Node) *PostBlock {
+	block := &PostBlock{Type: PostBlockQuote}
+
+	s := goquery.NewDocumentFromNode(n).Selection
+	header := s.Find(postQuoteHeaderSelector).First()
+	if header.Length() > 0 {
+		headerText := strings.TrimSpace(header.Text())
+		if i := strings.Index(headerText, postQuoteAuthorSuffix); i >= 0 {
+			headerText = headerText[:i]
+		}
+
+		header.Find("a").Each(func(_ int, a *goquery.Selection) {
+			linkText := strings.TrimSpace(a.Text())
+			href, _ := a.Attr("href")
+
+			if u, err := url.Parse(href); err == nil && strings.HasPrefix(u.Fragment, "post_") {
+				block.PostID, _ = strconv.ParseInt(u.Fragment[5:], 10, 64)
+			}
+			if strings.HasPrefix(linkText, "#") {
+				block.Serial, _ = strconv.ParseInt(strings.Trim(linkText[1:], " .:"), 10, 64)
+				headerText = strings.Replace(headerText, linkText, "", 1)
+			}
+		})
+
+		block.Author = strings.TrimSpace(headerText)
+	}
+
+	var body bytes.Buffer
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && hasClass(c, postQuoteHeaderClass) {
+			continue
+		}
+		body.WriteString(nodeText(c))
+	}
+	block.Text = strings.TrimSpace(collapseNewLines(body.String()))
+
+	block.Blocks = pc.blocks(n)
+
+	return block
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+
+	return ""
+}
+
+func hasClass(n *html.Node, class string) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+
+	for _, c := range strings.Fields(attr(n, "class")) {
+		if c == class {
+			return true
+		}
+	}
+
+	return false
+}
+
+// nodeText returns text of the node, line breaks are preserved.
+func nodeText(n *html.Node) string {
+	var buf bytes.Buffer
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			buf.WriteString(n.Data)
+		case n.Type == html.ElementNode && n.DataAtom == atom.Br:
+			buf.WriteString("\n")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+
+	return buf.String()
+}
+
+// collapseNewLines trims trailing spaces of every line and leaves at most one empty line in a row.
+func collapseNewLines(s string) string {
+	lines := strings.Split(s, "\n")
+	out := make([]string, 0, len(lines))
+
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" && len(out) > 0 && out[len(out)-1] == "" {
+			continue
+		}
+		out = append(out, line)
+	}
+
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/assert"
+)
+
+const testPostBody = `<div class="post_body">` +
+	`<div class="cite"><div class="cite_head"><a href="/temat/1#post_1001">#1</a> nick napisał:</div>first <b>quoted</b></div>` +
+	`Look <b>here</b>: <a href="/temat/2">topic</a><br>` +
+	`<img src="http://example.com/a.png" alt="a"><script>alert(1)</script>` +
+	`<a href="javascript:alert(1)">bad</a>` +
+	`<iframe src="https://www.youtube.com/embed/xyz"></iframe>` +
+	`<div class="code">x := 1</div>` +
+	`</div>`
+
+func TestNewPostContentFromSelection(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(testPostBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, _ := url.Parse("http://netwars.pl/temat/1")
+
+	content := NewPostContentFromSelection(doc.Find("div.post_body"), base)
+
+	assert.NotContains(t, content.HTML, "script")
+	assert.NotContains(t, content.HTML, "javascript")
+	assert.Contains(t, content.HTML, `<a href="http://netwars.pl/temat/2">topic</a>`)
+	assert.Contains(t, content.HTML, `<blockquote>`)
+	assert.Contains(t, content.HTML, `<iframe src="https://www.youtube.com/embed/xyz" allowfullscreen></iframe>`)
+
+	assert.Contains(t, content.Markdown, "> first **quoted**")
+	assert.Contains(t, content.Markdown, "Look **here**: [topic](http://netwars.pl/temat/2)")
+	assert.Contains(t, content.Markdown, "![a](http://example.com/a.png)")
+	assert.Contains(t, content.Markdown, "```\nx := 1\n```")
+
+	if assert.Len(t, content.Blocks, 7) {
+		quote := content.Blocks[0]
+		assert.Equal(t, PostBlockQuote, quote.Type)
+		assert.Equal(t, "nick", quote.Author)
+		assert.Equal(t, int64(1), quote.Serial)
+		assert.Equal(t, int64(1001), quote.PostID)
+		assert.Equal(t, "first quoted", quote.Text)
+
+		assert.Equal(t, &PostBlock{Type: PostBlockText, Text: "Look here:"}, content.Blocks[1])
+		assert.Equal(t, &PostBlock{Type: PostBlockLink, URL: "http://netwars.pl/temat/2", Text: "topic"}, content.Blocks[2])
+		assert.Equal(t, &PostBlock{Type: PostBlockImage, URL: "http://example.com/a.png", Text: "a"}, content.Blocks[3])
+		assert.Equal(t, &PostBlock{Type: PostBlockText, Text: "bad"}, content.Blocks[4])
+		assert.Equal(t, &PostBlock{Type: PostBlockVideo, URL: "https://www.youtube.com/embed/xyz"}, content.Blocks[5])
+		assert.Equal(t, &PostBlock{Type: PostBlockCode, Text: "x := 1"}, content.Blocks[6])
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strconv"
+	"time"
+)
+
+// postRevisionsLimit is a number of the most recent revisions kept for every post.
+const postRevisionsLimit = 20
+
+// PostRevision is a distinct version of the post body, seen during one of the refreshes.
+type PostRevision struct {
+	Version    int        `json:"version"`
+	HTML       string     `json:"html"`
+	Markdown   string     `json:"markdown"`
+	ModifiedAt *time.Time `json:"modifiedAt"`
+	ModifiedBy string     `json:"modifiedBy"`
+	SeenAt     time.Time  `json:"seenAt"`
+	// Diff is unified diff of markdown against previous revision, it is filled only in responses.
+	Diff string `json:"diff,omitempty"`
+}
+
+func newPostRevision(post *Post, version int, seenAt time.Time) *PostRevision {
+	return &PostRevision{
+		Version:    version,
+		HTML:       post.HTML,
+		Markdown:   post.Markdown,
+		ModifiedAt: post.ModifiedAt,
+		ModifiedBy: post.ModifiedBy,
+		SeenAt:     seenAt,
+	}
+}
+
+// Revisions returns versions of the post seen so far, the oldest first.
+// Only the last postRevisionsLimit of them are kept.
+func (p *Post) Revisions() []*PostRevision {
+	return p.revisions
+}
+
+// trackRevisions carries revisions over from the previous version of the topic
+// and adds new one to every post whose body changed. Revisions of previous version are never modified.
+func trackRevisions(prev, next *Topic, seenAt time.Time) {
+	prevPosts := make(map[int64]*Post)
+	if prev != nil {
+		for _, post := range prev.Posts {
+			prevPosts[post.Serial] = post
+		}
+	}
+
+	for _, post := range next.Posts {
+		old, ok := prevPosts[post.Serial]
+		if !ok || len(old.revisions) == 0 {
+			post.revisions = []*PostRevision{newPostRevision(post, 1, seenAt)}
+			continue
+		}
+
+		last := old.revisions[len(old.revisions)-1]
+		if last.HTML == post.HTML {
+			post.revisions = old.revisions
+			continue
+		}
+
+		kept := old.revisions
+		if len(kept) >= postRevisionsLimit {
+			kept = kept[len(kept)-postRevisionsLimit+1:]
+		}
+		revisions := make([]*PostRevision, 0, len(kept)+1)
+		revisions = append(revisions, kept...)
+		post.revisions = append(revisions, newPostRevision(post, last.Version+1, seenAt))
+	}
+}
+
+// diffRevisions returns copies of revisions, each with diff against the previous one.
+func diffRevisions(revisions []*PostRevision) []*PostRevision {
+	diffed := make([]*PostRevision, 0, len(revisions))
+
+	for i, revision := range revisions {
+		r := *revision
+		if i > 0 {
+			prev := revisions[i-1]
+			r.Diff = unifiedDiff(prev.Markdown, r.Markdown, "v"+strconv.Itoa(prev.Version), "v"+strconv.Itoa(r.Version))
+		}
+
+		diffed = append(diffed, &r)
+	}
+
+	return diffed
+}
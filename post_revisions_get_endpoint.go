@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/piotrkowalczuk/rest"
+	"golang.org/x/net/context"
+)
+
+// PostRevisionsGetResponse ...
+type PostRevisionsGetResponse struct {
+	TopicID   int             `json:"topicId"`
+	Serial    int64           `json:"serial"`
+	Revisions []*PostRevision `json:"revisions"`
+	// Truncated is set if the oldest revisions were dropped, see postRevisionsLimit.
+	Truncated bool `json:"truncated"`
+}
+
+// PostRevisionsGetEndpoint returns versions of the post seen so far, the oldest first,
+// each with unified diff against the previous one. Only the most recent ones are kept, response tells if any were dropped.
+func PostRevisionsGetEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(PostRevisionsGetRequest)
+	if !ok {
+		return nil, rest.InternalServerError(errBadCast, internalServerErrorMessage, 0)
+	}
+
+	storage, err := TopicStorageFromContext(ctx)
+	if err != nil {
+		return nil, rest.InternalServerError(err, internalServerErrorMessage, 0)
+	}
+
+	topic, err := storage.GetOrRetrieve(ctx, req.TopicID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, post := range topic.Posts {
+		if post.Serial == req.Serial {
+			revisions := post.Revisions()
+
+			return &PostRevisionsGetResponse{
+				TopicID:   topic.ID,
+				Serial:    post.Serial,
+				Revisions: diffRevisions(revisions),
+				// versions are numbered from one without gaps, so dropped ones are missing from the beginning
+				Truncated: len(revisions) > 0 && revisions[0].Version > 1,
+			}, nil
+		}
+	}
+
+	return nil, &rest.Error{Message: "post not found", HTTPCode: http.StatusNotFound}
+}
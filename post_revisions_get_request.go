@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/piotrkowalczuk/rest"
+	"golang.org/x/net/context"
+)
+
+// PostRevisionsGetRequest ...
+type PostRevisionsGetRequest struct {
+	TopicID int
+	Serial  int64
+}
+
+// PostRevisionsGetRequestDecode ...
+func PostRevisionsGetRequestDecode(ctx context.Context, _ *http.Request) (interface{}, error) {
+	topicID, err := rest.ParamFromContextInt(ctx, "topicId")
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := rest.ParamFromContextInt(ctx, "serial")
+	if err != nil {
+		return nil, err
+	}
+
+	return PostRevisionsGetRequest{
+		TopicID: topicID,
+		Serial:  int64(serial),
+	}, nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import "strings"
+
+// PostNode is a post together with posts that replied to it.
+type PostNode struct {
+	*Post
+	Replies []*PostNode `json:"replies"`
+}
+
+// resolveReplies links posts with posts they quote. Quote is resolved by post anchor or serial if it has them,
+// otherwise by author and text of the quote, which has to be found in one of the earlier posts.
+// Given posts are not modified, because they can be shared with previous version of the topic, copies are returned instead.
+func resolveReplies(posts []*Post) []*Post {
+	resolved := make([]*Post, 0, len(posts))
+	byID := make(map[int64]*Post, len(posts))
+	bySerial := make(map[int64]*Post, len(posts))
+
+	for _, post := range posts {
+		p := *post
+		p.ReplyTo = nil
+		p.RepliedBy = nil
+
+		resolved = append(resolved, &p)
+		byID[p.ID] = &p
+		bySerial[p.Serial] = &p
+	}
+
+	for i, post := range resolved {
+		for _, block := range post.Blocks {
+			if block.Type != PostBlockQuote {
+				continue
+			}
+
+			var quoted *Post
+
+			switch {
+			case block.PostID != 0:
+				quoted = byID[block.PostID]
+			case block.Serial != 0:
+				quoted = bySerial[block.Serial]
+			default:
+				quoted = findQuotedPost(resolved[:i], block)
+			}
+
+			if quoted == nil || quoted == post || quoted.Serial > post.Serial || containsInt64(post.ReplyTo, quoted.Serial) {
+				continue
+			}
+
+			post.ReplyTo = append(post.ReplyTo, quoted.Serial)
+			quoted.RepliedBy = append(quoted.RepliedBy, post.Serial)
+		}
+	}
+
+	return resolved
+}
+
+// findQuotedPost looks for the latest post that contains text of the quote and, if quote names an author, was written by them.
+func findQuotedPost(earlier []*Post, quote *PostBlock) *Post {
+	text := normalizeQuoteText(quote.Text)
+	if text == "" {
+		return nil
+	}
+
+	for i := len(earlier) - 1; i >= 0; i-- {
+		post := earlier[i]
+		if quote.Author != "" && !strings.EqualFold(post.CreatedBy, quote.Author) {
+			continue
+		}
+		if strings.Contains(normalizeQuoteText(post.Content), text) {
+			return post
+		}
+	}
+
+	return nil
+}
+
+func normalizeQuoteText(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+func containsInt64(s []int64, v int64) bool {
+	for _, i := range s {
+		if i == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewPostTree arranges posts into conversation tree. Post that quotes others is placed under the first one it replies to.
+// Posts that do not reply to anything are roots.
+func NewPostTree(posts []*Post) []*PostNode {
+	nodes := make(map[int64]*PostNode, len(posts))
+	roots := make([]*PostNode, 0)
+
+	for _, post := range posts {
+		nodes[post.Serial] = &PostNode{Post: post, Replies: []*PostNode{}}
+	}
+
+	for _, post := range posts {
+		node := nodes[post.Serial]
+
+		if len(post.ReplyTo) > 0 {
+			if parent, ok := nodes[post.ReplyTo[0]]; ok {
+				parent.Replies = append(parent.Replies, node)
+				continue
+			}
+		}
+
+		roots = append(roots, node)
+	}
+
+	return roots
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveReplies(t *testing.T) {
+	posts := []*Post{
+		{ID: 101, Serial: 1, CreatedBy: "alice", Content: "StarCraft is the best game ever made."},
+		{ID: 102, Serial: 2, CreatedBy: "bob", Content: "No way.", Blocks: []*PostBlock{
+			{Type: PostBlockQuote, Author: "alice", Text: "the best  game ever"},
+		}},
+		{ID: 103, Serial: 3, CreatedBy: "carol", Content: "Agreed.", Blocks: []*PostBlock{
+			{Type: PostBlockQuote, PostID: 102},
+			{Type: PostBlockQuote, Serial: 1},
+		}},
+		{ID: 104, Serial: 4, CreatedBy: "dave", Content: "?", Blocks: []*PostBlock{
+			{Type: PostBlockQuote, Author: "bob", Text: "something nobody wrote"},
+		}},
+	}
+
+	resolved := resolveReplies(posts)
+
+	assert.Equal(t, []int64{2, 3}, resolved[0].RepliedBy)
+	assert.Equal(t, []int64{1}, resolved[1].ReplyTo)
+	assert.Equal(t, []int64{3}, resolved[1].RepliedBy)
+	assert.Equal(t, []int64{2, 1}, resolved[2].ReplyTo)
+	assert.Nil(t, resolved[3].ReplyTo)
+	assert.Nil(t, posts[0].RepliedBy, "original posts should stay untouched")
+
+	tree := NewPostTree(resolved)
+	if assert.Len(t, tree, 2) {
+		assert.Equal(t, int64(1), tree[0].Serial)
+		assert.Equal(t, int64(4), tree[1].Serial)
+		if assert.Len(t, tree[0].Replies, 1) && assert.Len(t, tree[0].Replies[0].Replies, 1) {
+			assert.Equal(t, int64(3), tree[0].Replies[0].Replies[0].Serial)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/piotrkowalczuk/rest"
+)
+
+// pageMaxLimit is the largest number of elements returned by any list at once.
+const pageMaxLimit = 100
+
+// ErrOffsetOutOfRange is returned by stores if requested page starts past the end of the list.
+var ErrOffsetOutOfRange = errors.New("store: offset out of range")
+
+// parsePage reads offset and limit of the list. Missing or malformed values fall back to defaults,
+// negative ones are rejected, as they would point outside of the list. Limit is capped at pageMaxLimit.
+func parsePage(query url.Values) (int, int, error) {
+	offset, err := strconv.ParseInt(query.Get("offset"), 10, 32)
+	if err != nil {
+		offset = 0
+	}
+
+	limit, err := strconv.ParseInt(query.Get("limit"), 10, 32)
+	if err != nil {
+		limit = 10
+	}
+
+	if offset < 0 || limit < 0 {
+		return 0, 0, &rest.Error{Message: "offset and limit cannot be negative", HTTPCode: http.StatusBadRequest}
+	}
+	if limit > pageMaxLimit {
+		limit = pageMaxLimit
+	}
+
+	return int(offset), int(limit), nil
+}
@@ -0,0 +1,316 @@
+package search
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	snippetWords = 12
+	// HighlightStart and HighlightEnd surround matched words in snippets.
+	HighlightStart = "<em>"
+	HighlightEnd   = "</em>"
+)
+
+// Document is a single searchable piece of text. Documents are grouped,
+// so all documents that come from the same source can be replaced or removed at once.
+type Document struct {
+	Group     int
+	ID        int64
+	Text      string
+	Boost     float64
+	ForumID   int
+	Author    string
+	CreatedAt time.Time
+}
+
+// Query ...
+type Query struct {
+	Text   string
+	Filter func(*Document) bool
+	// Offset skips given number of results, negative one is treated as zero. Limit of zero means no limit.
+	Offset int
+	Limit  int
+}
+
+// Result ...
+type Result struct {
+	*Document
+	Score   float64
+	Snippet string
+}
+
+type key struct {
+	group int
+	id    int64
+}
+
+type entry struct {
+	doc    *Document
+	length int
+}
+
+// Index is an in-memory inverted index. It is thread safe.
+type Index struct {
+	sync.RWMutex
+	postings map[string]map[key]int
+	docs     map[key]*entry
+	groups   map[int][]int64
+}
+
+// NewIndex ...
+func NewIndex() *Index {
+	return &Index{
+		postings: make(map[string]map[key]int),
+		docs:     make(map[key]*entry),
+		groups:   make(map[int][]int64),
+	}
+}
+
+// Set replaces all documents of the group with given ones.
+func (i *Index) Set(group int, docs []*Document) {
+	i.Lock()
+	defer i.Unlock()
+
+	i.remove(group)
+
+	ids := make([]int64, 0, len(docs))
+	for _, doc := range docs {
+		k := key{group: group, id: doc.ID}
+		terms := Tokenize(doc.Text)
+
+		for _, term := range terms {
+			postings, ok := i.postings[term]
+			if !ok {
+				postings = make(map[key]int)
+				i.postings[term] = postings
+			}
+			postings[k]++
+		}
+
+		i.docs[k] = &entry{doc: doc, length: len(terms)}
+		ids = append(ids, doc.ID)
+	}
+	i.groups[group] = ids
+}
+
+// Remove drops all documents of the group.
+func (i *Index) Remove(group int) {
+	i.Lock()
+	defer i.Unlock()
+
+	i.remove(group)
+}
+
+// Len returns number of indexed documents.
+func (i *Index) Len() int {
+	i.RLock()
+	defer i.RUnlock()
+
+	return len(i.docs)
+}
+
+func (i *Index) remove(group int) {
+	for _, id := range i.groups[group] {
+		k := key{group: group, id: id}
+		entry, ok := i.docs[k]
+		if !ok {
+			continue
+		}
+
+		for _, term := range Tokenize(entry.doc.Text) {
+			if postings, ok := i.postings[term]; ok {
+				delete(postings, k)
+				if len(postings) == 0 {
+					delete(i.postings, term)
+				}
+			}
+		}
+
+		delete(i.docs, k)
+	}
+
+	delete(i.groups, group)
+}
+
+// Search returns documents that contain all terms of the query, best matches first.
+// Score is a sum of tf-idf weights of query terms, normalized by document length and multiplied by document boost.
+func (i *Index) Search(q Query) []*Result {
+	terms := unique(Tokenize(q.Text))
+	if len(terms) == 0 {
+		return []*Result{}
+	}
+
+	i.RLock()
+	defer i.RUnlock()
+
+	// start from the rarest term to keep candidate set small
+	sort.Sort(byFrequency{terms: terms, postings: i.postings})
+
+	results := make(results, 0)
+	for k, tf := range i.postings[terms[0]] {
+		entry := i.docs[k]
+		if q.Filter != nil && !q.Filter(entry.doc) {
+			continue
+		}
+
+		score := i.weight(terms[0], tf)
+		matched := true
+		for _, term := range terms[1:] {
+			tf, ok := i.postings[term][k]
+			if !ok {
+				matched = false
+				break
+			}
+			score += i.weight(term, tf)
+		}
+		if !matched {
+			continue
+		}
+
+		boost := entry.doc.Boost
+		if boost == 0 {
+			boost = 1
+		}
+
+		results = append(results, &Result{
+			Document: entry.doc,
+			Score:    boost * score / math.Sqrt(float64(entry.length)),
+		})
+	}
+
+	sort.Sort(results)
+
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+	if q.Offset > len(results) {
+		return []*Result{}
+	}
+	results = results[q.Offset:]
+	if q.Limit > 0 && q.Limit < len(results) {
+		results = results[:q.Limit]
+	}
+
+	for _, result := range results {
+		result.Snippet = Snippet(result.Text, terms)
+	}
+
+	return results
+}
+
+// weight is not thread safe!
+func (i *Index) weight(term string, tf int) float64 {
+	return float64(tf) * math.Log(1+float64(len(i.docs))/float64(len(i.postings[term])))
+}
+
+// Snippet returns fragment of the text around the first matched term with all matched words highlighted.
+func Snippet(text string, terms []string) string {
+	words := strings.Fields(text)
+	wanted := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		wanted[term] = true
+	}
+
+	matches := func(word string) bool {
+		for _, w := range Tokenize(word) {
+			if wanted[w] {
+				return true
+			}
+		}
+		return false
+	}
+
+	first := 0
+	for i, word := range words {
+		if matches(word) {
+			first = i
+			break
+		}
+	}
+
+	start := first - snippetWords/2
+	if start < 0 {
+		start = 0
+	}
+	end := start + snippetWords
+	if end > len(words) {
+		end = len(words)
+	}
+
+	parts := make([]string, 0, end-start+2)
+	if start > 0 {
+		parts = append(parts, "…")
+	}
+	for _, word := range words[start:end] {
+		if matches(word) {
+			parts = append(parts, HighlightStart+html.EscapeString(word)+HighlightEnd)
+		} else {
+			parts = append(parts, html.EscapeString(word))
+		}
+	}
+	if end < len(words) {
+		parts = append(parts, "…")
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func unique(terms []string) []string {
+	seen := make(map[string]bool, len(terms))
+	out := terms[:0]
+
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			out = append(out, term)
+		}
+	}
+
+	return out
+}
+
+type results []*Result
+
+// Len implements sort.Interface.
+func (r results) Len() int {
+	return len(r)
+}
+
+// Swap implements sort.Interface.
+func (r results) Swap(i, j int) {
+	r[i], r[j] = r[j], r[i]
+}
+
+// Less implements sort.Interface, higher scores go first.
+func (r results) Less(i, j int) bool {
+	if r[i].Score == r[j].Score {
+		return r[i].CreatedAt.After(r[j].CreatedAt)
+	}
+
+	return r[i].Score > r[j].Score
+}
+
+type byFrequency struct {
+	terms    []string
+	postings map[string]map[key]int
+}
+
+// Len implements sort.Interface.
+func (bf byFrequency) Len() int {
+	return len(bf.terms)
+}
+
+// Swap implements sort.Interface.
+func (bf byFrequency) Swap(i, j int) {
+	bf.terms[i], bf.terms[j] = bf.terms[j], bf.terms[i]
+}
+
+// Less implements sort.Interface.
+func (bf byFrequency) Less(i, j int) bool {
+	return len(bf.postings[bf.terms[i]]) < len(bf.postings[bf.terms[j]])
+}
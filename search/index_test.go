@@ -0,0 +1,63 @@
+package search_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/netwars/api/search"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := map[string]string{
+		"Żółć":      "zolc",
+		"zergami":   "zerg",
+		"Zergów":    "zerg",
+		"zergowi":   "zerg",
+		"protossem": "protoss",
+		"gra":       "gra",
+	}
+
+	for given, expected := range cases {
+		assert.Equal(t, expected, search.Normalize(given), given)
+	}
+}
+
+func TestIndex_Search(t *testing.T) {
+	now := time.Now()
+	index := search.NewIndex()
+	index.Set(1, []*search.Document{
+		{Group: 1, ID: 0, Text: "Strategia przeciwko zergom", Boost: 2, ForumID: 12, CreatedAt: now},
+		{Group: 1, ID: 1, Text: "Gram protossem i zawsze przegrywam z zergami.", ForumID: 12, Author: "alice", CreatedAt: now},
+		{Group: 1, ID: 2, Text: "Ćwicz makro.", ForumID: 12, Author: "bob", CreatedAt: now},
+	})
+	index.Set(2, []*search.Document{
+		{Group: 2, ID: 1, Text: "Zerg rush w Off Topicu", ForumID: 4, Author: "bob", CreatedAt: now},
+	})
+
+	results := index.Search(search.Query{Text: "zerg"})
+	if assert.Len(t, results, 3) {
+		assert.Equal(t, int64(0), results[0].ID, "boosted title should win")
+	}
+
+	results = index.Search(search.Query{Text: "ZERGÓW protoss"})
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, int64(1), results[0].ID)
+		assert.True(t, strings.Contains(results[0].Snippet, "<em>protossem</em>"), results[0].Snippet)
+		assert.True(t, strings.Contains(results[0].Snippet, "<em>zergami.</em>"), results[0].Snippet)
+	}
+
+	results = index.Search(search.Query{Text: "zerg", Filter: func(doc *search.Document) bool {
+		return doc.ForumID == 4
+	}})
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, 2, results[0].Group)
+	}
+
+	assert.Len(t, index.Search(search.Query{Text: "cwicz"}), 1)
+
+	index.Remove(1)
+	assert.Equal(t, 1, index.Len())
+	assert.Len(t, index.Search(search.Query{Text: "zerg"}), 1)
+}
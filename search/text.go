@@ -0,0 +1,69 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+var (
+	// folding maps Polish diacritics to their ASCII counterparts.
+	folding = map[rune]rune{
+		'ą': 'a', 'ć': 'c', 'ę': 'e', 'ł': 'l', 'ń': 'n', 'ó': 'o', 'ś': 's', 'ź': 'z', 'ż': 'z',
+	}
+	// suffixes are common Polish inflectional endings (already folded), longest first.
+	suffixes = []string{
+		"owego", "owych", "owymi", "owania",
+		"ach", "ami", "ego", "emu", "ich", "imi", "owi", "ych", "ymi", "iem", "owa", "owe", "owy", "cie",
+		"am", "em", "ie", "om", "ow", "ej", "mi", "ia", "iu",
+		"a", "e", "i", "o", "u", "y",
+	}
+)
+
+const (
+	// minStemLength is the shortest stem left after suffix is stripped.
+	minStemLength = 3
+)
+
+// Tokenize splits text into normalized terms.
+func Tokenize(text string) []string {
+	words := words(text)
+	terms := make([]string, 0, len(words))
+
+	for _, word := range words {
+		if term := Normalize(word); term != "" {
+			terms = append(terms, term)
+		}
+	}
+
+	return terms
+}
+
+// Normalize lowercases the word, folds diacritics and strips inflectional suffix.
+func Normalize(word string) string {
+	word = strings.Map(func(r rune) rune {
+		r = unicode.ToLower(r)
+		if f, ok := folding[r]; ok {
+			return f
+		}
+		return r
+	}, word)
+
+	return stem(word)
+}
+
+func stem(word string) string {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(word, suffix) && len(word)-len(suffix) >= minStemLength {
+			return word[:len(word)-len(suffix)]
+		}
+	}
+
+	return word
+}
+
+// words splits text on everything that is neither letter nor digit.
+func words(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/netwars/api/search"
+	"github.com/piotrkowalczuk/rest"
+	"golang.org/x/net/context"
+)
+
+// SearchGetEndpoint returns posts and topics matching the query, best matches first.
+func SearchGetEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(SearchGetRequest)
+	if !ok {
+		return nil, rest.InternalServerError(errBadCast, internalServerErrorMessage, 0)
+	}
+
+	index, err := SearchIndexFromContext(ctx)
+	if err != nil {
+		return nil, rest.InternalServerError(err, internalServerErrorMessage, 0)
+	}
+
+	// limit of zero means no limit to the index, but like in other lists it gives an empty page
+	if req.Limit == 0 {
+		return []map[string]interface{}{}, nil
+	}
+
+	results := index.Search(search.Query{
+		Text:   req.Query,
+		Offset: req.Offset,
+		Limit:  req.Limit,
+		Filter: func(doc *search.Document) bool {
+			if req.ForumID != 0 && doc.ForumID != req.ForumID {
+				return false
+			}
+			if req.Author != "" && !strings.EqualFold(doc.Author, req.Author) {
+				return false
+			}
+			if req.From != nil && doc.CreatedAt.Before(*req.From) {
+				return false
+			}
+			if req.To != nil && doc.CreatedAt.After(*req.To) {
+				return false
+			}
+			return true
+		},
+	})
+
+	response := make([]map[string]interface{}, 0, len(results))
+	for _, result := range results {
+		response = append(response, map[string]interface{}{
+			"topicId":   result.Group,
+			"serial":    result.ID,
+			"forumId":   result.ForumID,
+			"title":     index.Title(result.Group),
+			"createdBy": result.Author,
+			"createdAt": result.CreatedAt,
+			"score":     result.Score,
+			"snippet":   result.Snippet,
+		})
+	}
+
+	return response, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/piotrkowalczuk/rest"
+	"golang.org/x/net/context"
+)
+
+const (
+	searchDateLayout = "2006-01-02"
+)
+
+// SearchGetRequest ...
+type SearchGetRequest struct {
+	Query   string
+	ForumID int
+	Author  string
+	From    *time.Time
+	To      *time.Time
+	Offset  int
+	Limit   int
+}
+
+// SearchGetRequestDecode ...
+func SearchGetRequestDecode(ctx context.Context, r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+
+	if query.Get("q") == "" {
+		return nil, &rest.Error{Message: "missing search query", HTTPCode: http.StatusBadRequest}
+	}
+
+	forumID, err := strconv.ParseInt(query.Get("forumId"), 10, 32)
+	if err != nil {
+		forumID = 0
+	}
+
+	offset, limit, err := parsePage(query)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := parseSearchDate(query.Get("from"), false)
+	if err != nil {
+		return nil, &rest.Error{Message: "malformed from date", HTTPCode: http.StatusBadRequest}
+	}
+
+	to, err := parseSearchDate(query.Get("to"), true)
+	if err != nil {
+		return nil, &rest.Error{Message: "malformed to date", HTTPCode: http.StatusBadRequest}
+	}
+
+	return SearchGetRequest{
+		Query:   query.Get("q"),
+		ForumID: int(forumID),
+		Author:  query.Get("author"),
+		From:    from,
+		To:      to,
+		Offset:  offset,
+		Limit:   limit,
+	}, nil
+}
+
+// parseSearchDate accepts both RFC 3339 timestamps and plain dates. Plain date is the beginning of the day,
+// unless endOfDay is set, then it is the last moment of the day, so the whole day is included by upper bounds.
+func parseSearchDate(raw string, endOfDay bool) (*time.Time, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, raw)
+	if err == nil {
+		return &t, nil
+	}
+
+	if t, err = time.Parse(searchDateLayout, raw); err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+
+	return &t, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/netwars/api/search"
+)
+
+const (
+	// searchTitleBoost makes matches in topic title more relevant than matches in posts.
+	searchTitleBoost = 2
+)
+
+// SearchIndex keeps titles and posts of all cached topics in full-text index.
+type SearchIndex struct {
+	*search.Index
+	titles struct {
+		sync.RWMutex
+		byTopic map[int]string
+	}
+}
+
+// NewSearchIndex ...
+func NewSearchIndex() *SearchIndex {
+	si := &SearchIndex{
+		Index: search.NewIndex(),
+	}
+	si.titles.byTopic = make(map[int]string)
+
+	return si
+}
+
+// IndexTopic implements TopicIndexer. Title is indexed as a document with serial 0.
+func (si *SearchIndex) IndexTopic(topic *Topic) {
+	docs := make([]*search.Document, 0, len(topic.Posts)+1)
+
+	title := &search.Document{
+		Group:   topic.ID,
+		Text:    topic.Title,
+		Boost:   searchTitleBoost,
+		ForumID: topic.ForumID,
+	}
+	if topic.UpdatedAt != nil {
+		title.CreatedAt = *topic.UpdatedAt
+	}
+	docs = append(docs, title)
+
+	for _, post := range topic.Posts {
+		doc := &search.Document{
+			Group:   topic.ID,
+			ID:      post.Serial,
+			Text:    post.Content,
+			ForumID: topic.ForumID,
+			Author:  post.CreatedBy,
+		}
+		if post.CreatedAt != nil {
+			doc.CreatedAt = *post.CreatedAt
+		}
+		docs = append(docs, doc)
+	}
+
+	si.Set(topic.ID, docs)
+
+	si.titles.Lock()
+	si.titles.byTopic[topic.ID] = topic.Title
+	si.titles.Unlock()
+}
+
+// RemoveTopic implements TopicIndexer.
+func (si *SearchIndex) RemoveTopic(id int) {
+	si.Remove(id)
+
+	si.titles.Lock()
+	delete(si.titles.byTopic, id)
+	si.titles.Unlock()
+}
+
+// Title returns title of indexed topic.
+func (si *SearchIndex) Title(topicID int) string {
+	si.titles.RLock()
+	defer si.titles.RUnlock()
+
+	return si.titles.byTopic[topicID]
+}
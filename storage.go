@@ -0,0 +1,207 @@
+package main
+
+import (
+	"crypto/sha1"
+	"sync"
+	"time"
+)
+
+// TopicBackend persists topics, so they survive restarts.
+// TopicStore keeps serving from memory and writes every change through to the backend.
+type TopicBackend interface {
+	// PutTopic stores topic. Time it was stored at is what expiration is counted from after restart, until topic is read.
+	PutTopic(*Topic, time.Time) error
+	// TouchTopic records that topic was read, so after restart expiration is counted from that moment.
+	// Topic that is not stored is ignored.
+	TouchTopic(int, time.Time) error
+	DeleteTopic(int) error
+	// GetTopic returns stored topic together with the time it was last stored or read at, or nil if there is no such topic.
+	GetTopic(int) (*Topic, time.Time, error)
+	// Topics calls fn for every stored topic together with the time it was last stored or read at.
+	Topics(fn func(topic *Topic, usedAt time.Time) error) error
+	Close() error
+}
+
+// MemoryBackend is a TopicBackend that keeps topics in a map. Nothing survives restart.
+type MemoryBackend struct {
+	sync.RWMutex
+	topics map[int]*storedTopic
+}
+
+// NewMemoryBackend ...
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		topics: make(map[int]*storedTopic),
+	}
+}
+
+// PutTopic implements TopicBackend.
+func (mb *MemoryBackend) PutTopic(topic *Topic, storedAt time.Time) error {
+	mb.Lock()
+	defer mb.Unlock()
+
+	mb.topics[topic.ID] = &storedTopic{Topic: topic, StoredAt: storedAt}
+
+	return nil
+}
+
+// TouchTopic implements TopicBackend.
+func (mb *MemoryBackend) TouchTopic(id int, accessedAt time.Time) error {
+	mb.Lock()
+	defer mb.Unlock()
+
+	if st, ok := mb.topics[id]; ok {
+		st.AccessedAt = accessedAt
+	}
+
+	return nil
+}
+
+// DeleteTopic implements TopicBackend.
+func (mb *MemoryBackend) DeleteTopic(id int) error {
+	mb.Lock()
+	defer mb.Unlock()
+
+	delete(mb.topics, id)
+
+	return nil
+}
+
+// GetTopic implements TopicBackend.
+func (mb *MemoryBackend) GetTopic(id int) (*Topic, time.Time, error) {
+	mb.RLock()
+	defer mb.RUnlock()
+
+	st, ok := mb.topics[id]
+	if !ok {
+		return nil, time.Time{}, nil
+	}
+
+	return st.Topic, st.usedAt(), nil
+}
+
+// Topics implements TopicBackend.
+func (mb *MemoryBackend) Topics(fn func(*Topic, time.Time) error) error {
+	mb.RLock()
+	defer mb.RUnlock()
+
+	for _, st := range mb.topics {
+		if err := fn(st.Topic, st.usedAt()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close implements TopicBackend.
+func (mb *MemoryBackend) Close() error {
+	return nil
+}
+
+// storedTopic is a serializable form of the Topic. Validators of its pages are kept as well,
+// so the first refresh after restart can still be conditional.
+type storedTopic struct {
+	Topic    *Topic        `json:"topic"`
+	Pages    []*storedPage `json:"pages"`
+	StoredAt time.Time     `json:"storedAt"`
+	// AccessedAt is the time topic was last read, if it was read after it was stored.
+	AccessedAt time.Time `json:"accessedAt"`
+	// Revisions are kept only for posts that were edited, the only revision of the others is the post itself.
+	Revisions map[int64][]*PostRevision `json:"revisions,omitempty"`
+}
+
+type storedPage struct {
+	ETag         string  `json:"etag"`
+	LastModified string  `json:"lastModified"`
+	Hash         []byte  `json:"hash"`
+	Serials      []int64 `json:"serials"`
+}
+
+func newStoredTopic(topic *Topic, storedAt time.Time) *storedTopic {
+	st := &storedTopic{
+		Topic:    topic,
+		Pages:    make([]*storedPage, 0, len(topic.pages)),
+		StoredAt: storedAt,
+	}
+
+	for _, page := range topic.pages {
+		if page == nil {
+			st.Pages = append(st.Pages, nil)
+			continue
+		}
+
+		sp := &storedPage{
+			ETag:         page.etag,
+			LastModified: page.lastModified,
+			Hash:         page.hash[:],
+			Serials:      make([]int64, 0, len(page.posts)),
+		}
+		for _, post := range page.posts {
+			sp.Serials = append(sp.Serials, post.Serial)
+		}
+
+		st.Pages = append(st.Pages, sp)
+	}
+
+	for _, post := range topic.Posts {
+		if len(post.revisions) > 1 {
+			if st.Revisions == nil {
+				st.Revisions = make(map[int64][]*PostRevision)
+			}
+			st.Revisions[post.Serial] = post.revisions
+		}
+	}
+
+	return st
+}
+
+// usedAt returns the later of the times topic was stored and read at.
+func (st *storedTopic) usedAt() time.Time {
+	if st.AccessedAt.After(st.StoredAt) {
+		return st.AccessedAt
+	}
+
+	return st.StoredAt
+}
+
+// topic restores pages of the topic. Posts of every page are taken from the topic itself.
+func (st *storedTopic) topic() *Topic {
+	topic := st.Topic
+
+	bySerial := make(map[int64]*Post, len(topic.Posts))
+	for _, post := range topic.Posts {
+		bySerial[post.Serial] = post
+
+		post.revisions = st.Revisions[post.Serial]
+		if len(post.revisions) == 0 {
+			post.revisions = []*PostRevision{newPostRevision(post, 1, st.StoredAt)}
+		}
+	}
+
+	topic.pages = make([]*topicPage, 0, len(st.Pages))
+	for _, sp := range st.Pages {
+		if sp == nil {
+			topic.pages = append(topic.pages, nil)
+			continue
+		}
+
+		page := &topicPage{
+			etag:         sp.ETag,
+			lastModified: sp.LastModified,
+			posts:        make([]*Post, 0, len(sp.Serials)),
+		}
+		if len(sp.Hash) == sha1.Size {
+			copy(page.hash[:], sp.Hash)
+		}
+		for _, serial := range sp.Serials {
+			if post, ok := bySerial[serial]; ok {
+				page.posts = append(page.posts, post)
+			}
+		}
+
+		topic.pages = append(topic.pages, page)
+	}
+
+	return topic
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// ThrottleOpts ...
+type ThrottleOpts struct {
+	// RequestsPerSecond limits number of requests across all hosts, zero means no limit.
+	RequestsPerSecond float64
+	// HostDelay is a minimal delay between two consecutive requests to the same host.
+	HostDelay time.Duration
+}
+
+// Throttle limits global request rate and keeps politeness delay between requests to the same host.
+type Throttle struct {
+	sync.Mutex
+	interval  time.Duration
+	hostDelay time.Duration
+	next      time.Time
+	hosts     map[string]time.Time
+}
+
+// NewThrottle ...
+func NewThrottle(options ThrottleOpts) *Throttle {
+	t := &Throttle{
+		hostDelay: options.HostDelay,
+		hosts:     make(map[string]time.Time),
+	}
+
+	if options.RequestsPerSecond > 0 {
+		t.interval = time.Duration(float64(time.Second) / options.RequestsPerSecond)
+	}
+
+	return t
+}
+
+// Wait blocks until request to given host is allowed or context is done.
+func (t *Throttle) Wait(ctx context.Context, host string) error {
+	delay := t.reserve(host)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reserve books the nearest free slot for given host and returns how long caller has to wait for it.
+func (t *Throttle) reserve(host string) time.Duration {
+	t.Lock()
+	defer t.Unlock()
+
+	now := time.Now()
+	at := now
+	if t.next.After(at) {
+		at = t.next
+	}
+	if next, ok := t.hosts[host]; ok && next.After(at) {
+		at = next
+	}
+
+	t.next = at.Add(t.interval)
+	t.hosts[host] = at.Add(t.hostDelay)
+
+	return at.Sub(now)
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"crypto/sha1"
 	"errors"
+	"net/url"
 	"strings"
 	"time"
 
@@ -10,25 +12,63 @@ import (
 	"github.com/PuerkitoBio/goquery"
 )
 
+const (
+	topicTitleSelector          = "h1.topic_title"
+	topicTitleSuffix            = " - Netwars.pl"
+	topicListRowSelector        = "table tr"
+	topicListLinkSelector       = "td.topic > a[href^='/temat/']"
+	topicListPagesSelector      = "td.topic .pages a"
+	topicListAuthorSelector     = "td.author a.nick"
+	topicListCreatedAtSelector  = "td.author .date"
+	topicListRepliesSelector    = "td.replies"
+	topicListViewsSelector      = "td.views"
+	topicListLastPostBySelector = "td.last_post a.nick"
+	topicListLastPostAtSelector = "td.last_post .date"
+)
+
 // Topic ...
 type Topic struct {
-	ID        int        `json:"id"`
-	ForumID   int        `json:"forumId"`
-	Title     string     `json:"title"`
-	Posts     []*Post    `json:"posts"`
-	UpdatedAt *time.Time `json:"updatedAt"`
+	ID          int        `json:"id"`
+	ForumID     int        `json:"forumId"`
+	Title       string     `json:"title"`
+	Pages       int        `json:"pages"`
+	Posts       []*Post    `json:"posts"`
+	UpdatedAt   *time.Time `json:"updatedAt"`
+	CreatedAt   *time.Time `json:"createdAt"`
+	CreatedBy   string     `json:"createdBy"`
+	CreatedByID int        `json:"createdById"`
+	Replies     int        `json:"replies"`
+	LastPostBy  string     `json:"lastPostBy"`
+	// Views, Sticky, Announcement and Locked are known only from the forum listing, as of ListedAt.
+	Views        int        `json:"views"`
+	Sticky       bool       `json:"sticky"`
+	Announcement bool       `json:"announcement"`
+	Locked       bool       `json:"locked"`
+	ListedAt     *time.Time `json:"listedAt,omitempty"`
+	// LastRefreshedAt is the time the topic was last fetched, whether it changed or not.
+	LastRefreshedAt *time.Time `json:"lastRefreshedAt"`
+	// Stale is set for topics served from the archive, which are not refreshed anymore.
+	Stale bool `json:"stale"`
+	// DeletedAt is set for topics that do not exist anymore, the last seen version is kept.
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+
+	pages []*topicPage
+}
+
+// topicPage remembers validators and posts of a single page of the topic,
+// so that page can be requested conditionally and does not have to be parsed again if it did not change.
+type topicPage struct {
+	etag         string
+	lastModified string
+	hash         [sha1.Size]byte
+	posts        []*Post
 }
 
 // NewTopicFromDocument parse given document to find matching patterns and returns Topic instance if it is possible.
 func NewTopicFromDocument(doc *goquery.Document) (*Topic, error) {
-	parts := strings.Split(doc.Url.String(), "/")
-	if len(parts) < 3 || parts[len(parts)-2] != "temat" {
-		return nil, errors.New("malformed topic url")
-	}
-
-	topicID, err := strconv.ParseInt(parts[len(parts)-1], 10, 32)
+	topicID, err := topicIDFromURL(doc.Url)
 	if err != nil {
-		return nil, errors.New("malformed topic id in url")
+		return nil, err
 	}
 
 	forumLink, _ := doc.Find("ul.forum_navi a[href^='/forum/']").Attr("href")
@@ -41,7 +81,11 @@ func NewTopicFromDocument(doc *goquery.Document) (*Topic, error) {
 		return nil, errors.New("malformed forum id in url")
 	}
 
-	title := doc.Find("title").First().Text()
+	// <title> contains name of the site as well, it is used only if there is no heading
+	title := strings.TrimSpace(doc.Find(topicTitleSelector).First().Text())
+	if title == "" {
+		title = strings.TrimSuffix(strings.TrimSpace(doc.Find("title").First().Text()), topicTitleSuffix)
+	}
 	if title == "" {
 		return nil, errors.New("missing title in document")
 	}
@@ -59,3 +103,148 @@ func NewTopicFromDocument(doc *goquery.Document) (*Topic, error) {
 		UpdatedAt: date,
 	}, nil
 }
+
+// NewTopicsFromForumDocument parse forum listing page and returns topics described by its rows.
+// Returned topics have no posts, only metadata that is shown in the listing. Metadata missing from a row is left empty,
+// only a row with malformed link fails the whole page.
+func NewTopicsFromForumDocument(doc *goquery.Document, forumID int) (topics []*Topic, err error) {
+	listedAt := time.Now()
+
+	doc.Find(topicListRowSelector).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		link := s.Find(topicListLinkSelector).First()
+		href, exists := link.Attr("href")
+		if !exists {
+			return true
+		}
+
+		var topicID int64
+		topicID, err = strconv.ParseInt(href[7:], 10, 32)
+		if err != nil {
+			err = errors.New("malformed topic id in url")
+			return false
+		}
+
+		topic := &Topic{
+			ID:           int(topicID),
+			ForumID:      forumID,
+			Title:        strings.TrimSpace(link.Text()),
+			Pages:        1,
+			LastPostBy:   strings.TrimSpace(s.Find(topicListLastPostBySelector).Text()),
+			Sticky:       s.HasClass("sticky"),
+			Announcement: s.HasClass("announcement"),
+			Locked:       s.HasClass("locked"),
+			ListedAt:     &listedAt,
+		}
+
+		// topics of guests and removed accounts do not link to profile
+		author := s.Find(topicListAuthorSelector).First()
+		topic.CreatedBy = strings.TrimSpace(author.Text())
+		if href, ok := author.Attr("href"); ok {
+			topic.CreatedByID, _ = userIDFromHref(href)
+		}
+
+		// metadata that cannot be parsed is left empty, one odd row does not make the whole page unusable
+		if pages, err := strconv.Atoi(strings.TrimSpace(s.Find(topicListPagesSelector).Last().Text())); err == nil {
+			topic.Pages = pages
+		}
+		if date, err := parseDate(strings.TrimSpace(s.Find(topicListCreatedAtSelector).Text())); err == nil {
+			topic.CreatedAt = date
+		}
+		if date, err := parseDate(strings.TrimSpace(s.Find(topicListLastPostAtSelector).Text())); err == nil {
+			topic.UpdatedAt = date
+		}
+		topic.Replies, _ = parseCount(s.Find(topicListRepliesSelector).Text())
+		topic.Views, _ = parseCount(s.Find(topicListViewsSelector).Text())
+
+		topics = append(topics, topic)
+
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return topics, nil
+}
+
+// fillFromPosts sets metadata that can be derived from posts: author, creation date, number of replies and last poster.
+// Posts that were deleted are not counted.
+func (t *Topic) fillFromPosts() {
+	var first, last *Post
+
+	t.Replies = -1
+	for _, post := range t.Posts {
+		if post.DeletedAt != nil {
+			continue
+		}
+		if first == nil {
+			first = post
+		}
+		last = post
+		t.Replies++
+	}
+	if first == nil {
+		t.Replies = 0
+		return
+	}
+
+	t.CreatedAt = first.CreatedAt
+	t.CreatedBy = first.CreatedBy
+	t.CreatedByID = first.CreatedByID
+	t.LastPostBy = last.CreatedBy
+}
+
+// applyListing copies metadata known only from the forum listing.
+func (t *Topic) applyListing(listing *Topic) {
+	if listing == nil || listing.ListedAt == nil {
+		return
+	}
+
+	t.Views = listing.Views
+	t.Sticky = listing.Sticky
+	t.Announcement = listing.Announcement
+	t.Locked = listing.Locked
+	t.ListedAt = listing.ListedAt
+}
+
+// size approximates memory used by the topic, it counts only text of the topic and its posts.
+func (t *Topic) size() int64 {
+	size := int64(len(t.Title) + len(t.CreatedBy) + len(t.LastPostBy))
+	for _, post := range t.Posts {
+		size += int64(len(post.Content) + len(post.HTML) + len(post.Markdown) + len(post.CreatedBy) + len(post.ModifiedBy))
+		for _, revision := range post.revisions {
+			size += int64(len(revision.HTML) + len(revision.Markdown) + len(revision.Diff))
+		}
+	}
+
+	return size
+}
+
+// topicIDFromURL extracts topic id from urls like /temat/<id> or /temat/<id>/<page>.
+func topicIDFromURL(u *url.URL) (int64, error) {
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	for i := 0; i < len(parts)-1; i++ {
+		if parts[i] != "temat" {
+			continue
+		}
+
+		topicID, err := strconv.ParseInt(parts[i+1], 10, 32)
+		if err != nil {
+			return 0, errors.New("malformed topic id in url")
+		}
+
+		return topicID, nil
+	}
+
+	return 0, errors.New("malformed topic url")
+}
+
+// page returns remembered version of the page with given index, if any. It is safe to call on nil Topic.
+func (t *Topic) page(i int) *topicPage {
+	if t == nil || i >= len(t.pages) {
+		return nil
+	}
+
+	return t.pages[i]
+}
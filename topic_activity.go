@@ -0,0 +1,105 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+const (
+	// activityPostSample is the number of the latest posts used to estimate how busy the topic is.
+	activityPostSample = 5
+	// activityReadsHalfLife is the time after which a read counts only half as much.
+	activityReadsHalfLife = time.Hour
+	// activityReadsScale is the number of reads per hour that halves refresh interval.
+	activityReadsScale = 30
+)
+
+// topicActivity remembers when topic changed for the last time and how often it is read.
+type topicActivity struct {
+	changedAt time.Time
+	reads     float64
+	readAt    time.Time
+	interval  time.Duration
+}
+
+// read records single read of the topic.
+func (ta *topicActivity) read(now time.Time) {
+	ta.reads = ta.decayedReads(now) + 1
+	ta.readAt = now
+}
+
+// readsPerHour estimates current reading rate. With steady rate, decayed sum settles at rate times half-life divided by ln 2.
+func (ta *topicActivity) readsPerHour(now time.Time) float64 {
+	return ta.decayedReads(now) * math.Ln2 / activityReadsHalfLife.Hours()
+}
+
+func (ta *topicActivity) decayedReads(now time.Time) float64 {
+	if ta.reads == 0 {
+		return 0
+	}
+
+	return ta.reads * math.Exp2(-now.Sub(ta.readAt).Hours()/activityReadsHalfLife.Hours())
+}
+
+// expectedGap estimates time until the next post, based on gaps between the latest posts.
+// If topic has been quiet for longer than that, the quiet period is used instead.
+func (ta *topicActivity) expectedGap(topic *Topic, now time.Time) time.Duration {
+	var last, first *time.Time
+	var n int
+
+	for i := len(topic.Posts) - 1; i >= 0 && n < activityPostSample; i-- {
+		post := topic.Posts[i]
+		if post.DeletedAt != nil || post.CreatedAt == nil {
+			continue
+		}
+		if last == nil {
+			last = post.CreatedAt
+		}
+		first = post.CreatedAt
+		n++
+	}
+
+	quiet := now.Sub(ta.changedAt)
+	if last == nil {
+		return quiet
+	}
+	// dates of posts are in local time of the forum, so they can appear to be in the future, but never too old
+	if age := now.Sub(*last); age > quiet {
+		quiet = age
+	}
+	if n < 2 {
+		return quiet
+	}
+
+	if gap := last.Sub(*first) / time.Duration(n-1); gap > quiet {
+		return gap
+	}
+
+	return quiet
+}
+
+// refreshInterval returns how often topic should be refreshed: twice per expected gap between posts,
+// more often if topic is read a lot. Result is kept within given bounds and jittered, so topics are not refreshed in bursts.
+func refreshInterval(topic *Topic, activity *topicActivity, now time.Time, min, max time.Duration, jitter float64) time.Duration {
+	interval := activity.expectedGap(topic, now) / 2
+	interval = time.Duration(float64(interval) / (1 + activity.readsPerHour(now)/activityReadsScale))
+	interval = clampDuration(interval, min, max)
+
+	if jitter > 0 {
+		interval += time.Duration((rand.Float64()*2 - 1) * jitter * float64(interval))
+	}
+
+	return clampDuration(interval, min, max)
+}
+
+func clampDuration(d, min, max time.Duration) time.Duration {
+	switch {
+	case d < min:
+		return min
+	case d > max:
+		return max
+	}
+
+	return d
+}
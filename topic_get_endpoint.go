@@ -1,7 +1,6 @@
 package main
 
 import (
-	"github.com/go-kit/kit/endpoint"
 	"github.com/piotrkowalczuk/rest"
 	"golang.org/x/net/context"
 )
@@ -10,7 +9,7 @@ import (
 func TopicGetEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
 	req, ok := request.(TopicGetRequest)
 	if !ok {
-		return nil, rest.InternalServerError(endpoint.ErrBadCast, internalServerErrorMessage, 0)
+		return nil, rest.InternalServerError(errBadCast, internalServerErrorMessage, 0)
 	}
 
 	storage, err := TopicStorageFromContext(ctx)
@@ -18,5 +17,5 @@ func TopicGetEndpoint(ctx context.Context, request interface{}) (interface{}, er
 		return nil, rest.InternalServerError(err, internalServerErrorMessage, 0)
 	}
 
-	return storage.GetOrRetrieve(req.TopicID)
+	return storage.GetOrRetrieve(ctx, req.TopicID)
 }
@@ -3,57 +3,499 @@ package main
 import (
 	"errors"
 	"log"
+	"math"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/netwars/api/cache"
+	"golang.org/x/net/context"
+)
+
+const (
+	// topicTouchInterval is how often reads of the topic are persisted. After restart topic can expire at most that much too early.
+	topicTouchInterval = time.Hour
+	// tombstonesLimit is a number of deleted topics kept in memory, those deleted earliest are forgotten first.
+	tombstonesLimit = 10000
+	// tombstoneRecheck is how long deleted topic is served before it is fetched again on read, in case it was restored.
+	// It doubles after every check that finds topic still deleted, up to tombstoneRecheckMax.
+	tombstoneRecheck    = 10 * time.Minute
+	tombstoneRecheckMax = 7 * 24 * time.Hour
+)
+
+// TopicIndexer is notified about every topic stored in TopicStore and about every topic that expired.
+type TopicIndexer interface {
+	IndexTopic(*Topic)
+	RemoveTopic(int)
+}
+
+// RevivePolicy decides what happens when archived topic is read.
+type RevivePolicy int
+
+const (
+	// ReviveNever serves archived topics as they are.
+	ReviveNever RevivePolicy = iota
+	// ReviveOnRead brings archived topic back to the cache and refreshes it.
+	ReviveOnRead
 )
 
 // TopicStoreOpts ...
 type TopicStoreOpts struct {
-	WarmUp int
+	WarmUp   int
+	Indexers []TopicIndexer
+	// Events, if set, receives changes found between consecutive versions of every topic.
+	Events *EventBroker
+	// Backend, if set, persists every stored topic and topics stored there are restored by NewTopicStore.
+	// Without it topics live only in memory.
+	Backend TopicBackend
+	// Archive keeps expired topics in the backend, so they can still be read. It only stops their refreshing.
+	// If there is no backend, MemoryBackend is used. Evicted topics are not kept there,
+	// so limits of the cache still bound memory used by the archive.
+	Archive bool
+	Revive  RevivePolicy
+	// Deletions, if set, records posts and topics that were removed from the forum.
+	Deletions *DeletionLog
+	// MinInterval and MaxInterval bound refresh interval of every topic, that is adjusted to how often it gets new posts
+	// and how often it is read. If MaxInterval is zero, all topics are refreshed with the default interval of the cache.
+	MinInterval time.Duration
+	MaxInterval time.Duration
+	// IntervalJitter randomizes refresh intervals by given fraction, e.g. 0.1 means ±10%.
+	IntervalJitter float64
 }
 
 // TopicStore ...
 type TopicStore struct {
-	cache.Cache
+	*cache.Cache[int, *Topic]
 	err          chan error
 	client       Client
+	crawler      *Crawler
+	forums       *ForumStore
 	index        []int
+	forumIndex   map[int][]int
+	topicForum   map[int]int
+	indexers     []TopicIndexer
+	events       *EventBroker
+	backend      TopicBackend
+	archive      bool
+	memArchive   bool
+	revive       RevivePolicy
+	deletions    *DeletionLog
 	notification chan int
+	minInterval  time.Duration
+	maxInterval  time.Duration
+	jitter       float64
+	activity     struct {
+		sync.Mutex
+		topics map[int]*topicActivity
+	}
+	// persisted keeps the time expiration of every stored topic is counted from after restart.
+	persisted struct {
+		sync.Mutex
+		topics map[int]time.Time
+	}
+	tombstones struct {
+		sync.Mutex
+		topics map[int]*tombstone
+	}
+	// missing keeps topics reported deleted once, they are marked deleted only if the next refresh reports it again.
+	missing struct {
+		sync.Mutex
+		topics map[int]struct{}
+	}
+	// refreshing keeps topics whose refresh was requested by the cache, only their outcome is recorded in cache stats.
+	refreshing struct {
+		sync.Mutex
+		topics map[int]struct{}
+	}
 }
 
-// NewTopicStore ...
-func NewTopicStore(client Client, cache *cache.Cache, options TopicStoreOpts) *TopicStore {
+// tombstone is the last known version of deleted topic, together with the time it was checked for the last time.
+type tombstone struct {
+	topic     *Topic
+	checkedAt time.Time
+	checks    int
+}
+
+// NewTopicStore restores topics persisted by the backend, if any, before it starts refreshing them and warming up the cache,
+// so stored versions never replace fresh ones.
+func NewTopicStore(client Client, cache *cache.Cache[int, *Topic], crawler *Crawler, forums *ForumStore, options TopicStoreOpts) (*TopicStore, error) {
 	store := &TopicStore{
-		Cache:  *cache,
-		client: client,
-		err:    make(chan error, 1),
-		index:  make([]int, 0, 10000), // made up value
+		Cache:       cache,
+		client:      client,
+		crawler:     crawler,
+		forums:      forums,
+		err:         make(chan error, 1),
+		index:       make([]int, 0, 10000), // made up value
+		forumIndex:  make(map[int][]int),
+		topicForum:  make(map[int]int),
+		indexers:    options.Indexers,
+		events:      options.Events,
+		backend:     options.Backend,
+		archive:     options.Archive,
+		revive:      options.Revive,
+		deletions:   options.Deletions,
+		minInterval: options.MinInterval,
+		maxInterval: options.MaxInterval,
+		jitter:      options.IntervalJitter,
+	}
+	store.activity.topics = make(map[int]*topicActivity)
+	store.persisted.topics = make(map[int]time.Time)
+	store.tombstones.topics = make(map[int]*tombstone)
+	store.missing.topics = make(map[int]struct{})
+	store.refreshing.topics = make(map[int]struct{})
+	if store.archive && store.backend == nil {
+		store.backend = NewMemoryBackend()
+		store.memArchive = true
+	}
+
+	if err := store.restore(); err != nil {
+		return nil, err
 	}
 
 	go store.listenCache()
+	go store.listenCrawler()
 
 	if options.WarmUp > 0 {
 		go store.warmUp(options.WarmUp)
 	}
 
-	return store
+	return store, nil
 }
 
 // Set ...
 func (ts *TopicStore) Set(topic *Topic) {
+	prev, _ := ts.Peek(topic.ID)
+
+	// refreshed topic does not come from the forum listing, metadata seen there before is kept
+	if topic.ListedAt == nil {
+		topic.applyListing(prev)
+	}
+
+	now := time.Now()
+	topic.LastRefreshedAt = &now
+	trackRevisions(prev, topic, now)
+	deleted := tombstonePosts(prev, topic, now)
+
 	ts.Cache.Set(topic.ID, topic)
+	ts.indexTopic(topic)
+	ts.adjustInterval(topic, true, false)
+
+	// topic could be restored after it was deleted
+	ts.tombstones.Lock()
+	delete(ts.tombstones.topics, topic.ID)
+	ts.tombstones.Unlock()
+
+	ts.missing.Lock()
+	delete(ts.missing.topics, topic.ID)
+	ts.missing.Unlock()
+
+	if ts.backend != nil {
+		if err := ts.backend.PutTopic(topic, now); err != nil {
+			ts.err <- err
+		} else {
+			ts.persisted.Lock()
+			ts.persisted.topics[topic.ID] = now
+			ts.persisted.Unlock()
+		}
+	}
+
+	if ts.deletions != nil && len(deleted) > 0 {
+		deletions := make([]*Deletion, 0, len(deleted))
+		for _, post := range deleted {
+			deletions = append(deletions, &Deletion{
+				Type:      DeletionPost,
+				TopicID:   topic.ID,
+				ForumID:   topic.ForumID,
+				Serial:    post.Serial,
+				Title:     topic.Title,
+				Author:    post.CreatedBy,
+				DeletedAt: now,
+			})
+		}
+		ts.deletions.Add(deletions...)
+	}
+
+	if ts.events != nil {
+		if events := diffTopics(prev, topic); len(events) > 0 {
+			ts.events.Publish(events...)
+		}
+	}
+}
+
+// markRefreshed records that topic was fetched again and did not change.
+// Cached topic is replaced by its copy, as the previous one can be read concurrently.
+func (ts *TopicStore) markRefreshed(id int) {
+	now := time.Now()
 
+	ts.Cache.Update(id, func(prev *Topic) *Topic {
+		topic := *prev
+		topic.LastRefreshedAt = &now
+
+		return &topic
+	})
+}
+
+// confirmDeleted reports whether cached topic was reported deleted by two consecutive refreshes.
+// Single report is not trusted, as the forum can fail to serve existing topic for a while.
+func (ts *TopicStore) confirmDeleted(id int) bool {
+	if _, ok := ts.Peek(id); !ok {
+		return true
+	}
+
+	ts.missing.Lock()
+	defer ts.missing.Unlock()
+
+	if _, ok := ts.missing.topics[id]; ok {
+		delete(ts.missing.topics, id)
+		return true
+	}
+	ts.missing.topics[id] = struct{}{}
+
+	return false
+}
+
+// markDeleted replaces topic that does not exist anymore with its tombstone, the last known version with deletion time.
+// Tombstone is not refreshed, it is kept in memory and, if archive is enabled, in the backend.
+// It is fetched again when read, less and less often, see tombstoneRecheck.
+func (ts *TopicStore) markDeleted(id int) {
+	prev, ok := ts.Peek(id)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	tombstone := *prev
+	tombstone.DeletedAt = &now
+
+	ts.Cache.Delete(id)
+	ts.remove(id)
+
+	ts.addTombstone(&tombstone)
+
+	if ts.backend != nil && ts.archive {
+		if err := ts.backend.PutTopic(&tombstone, now); err != nil {
+			ts.err <- err
+		}
+	}
+
+	if ts.deletions != nil {
+		ts.deletions.Add(&Deletion{
+			Type:      DeletionTopic,
+			TopicID:   id,
+			ForumID:   prev.ForumID,
+			Title:     prev.Title,
+			DeletedAt: now,
+		})
+	}
+
+	if ts.events != nil {
+		ts.events.Publish(&Event{
+			Type:      EventTopicDeleted,
+			TopicID:   id,
+			ForumID:   prev.ForumID,
+			Title:     prev.Title,
+			CreatedAt: now,
+		})
+	}
+}
+
+// addTombstone keeps deleted topic in memory, forgetting the one deleted earliest if there are too many of them.
+func (ts *TopicStore) addTombstone(topic *Topic) {
+	ts.tombstones.Lock()
+	defer ts.tombstones.Unlock()
+
+	ts.tombstones.topics[topic.ID] = &tombstone{topic: topic, checkedAt: *topic.DeletedAt}
+	if len(ts.tombstones.topics) <= tombstonesLimit {
+		return
+	}
+
+	oldest := topic
+	for _, t := range ts.tombstones.topics {
+		if t.topic.DeletedAt.Before(*oldest.DeletedAt) {
+			oldest = t.topic
+		}
+	}
+	delete(ts.tombstones.topics, oldest.ID)
+}
+
+// tombstone returns deleted topic and reports whether it is time to check if it is still deleted.
+// Check is claimed by the caller, so concurrent reads do not fetch the topic all at once.
+func (ts *TopicStore) tombstone(id int) (topic *Topic, ok, recheck bool) {
+	ts.tombstones.Lock()
+	defer ts.tombstones.Unlock()
+
+	t, ok := ts.tombstones.topics[id]
+	if !ok {
+		return nil, false, false
+	}
+
+	backoff := tombstoneRecheckMax
+	if t.checks < 16 && tombstoneRecheck<<uint(t.checks) < backoff {
+		backoff = tombstoneRecheck << uint(t.checks)
+	}
+
+	now := time.Now()
+	if now.Sub(t.checkedAt) < backoff {
+		return t.topic, true, false
+	}
+	t.checkedAt = now
+	t.checks++
+
+	return t.topic, true, true
+}
+
+// restore loads topics persisted by the backend. Topics that would have expired in the meantime are dropped,
+// or left in the archive if it is enabled. The rest is cached for the remaining time,
+// counted from the moment they were last stored or read, and refreshed as usual.
+func (ts *TopicStore) restore() error {
+	if ts.backend == nil {
+		return nil
+	}
+
+	var expired []int
+
+	expiration := ts.Expiration()
+	err := ts.backend.Topics(func(topic *Topic, usedAt time.Time) error {
+		if topic.DeletedAt != nil {
+			ts.addTombstone(topic)
+			return nil
+		}
+
+		remaining := expiration - time.Since(usedAt)
+		if remaining <= 0 {
+			expired = append(expired, topic.ID)
+			return nil
+		}
+
+		ts.Cache.SetWithExpiration(topic.ID, topic, remaining)
+		ts.indexTopic(topic)
+		ts.adjustInterval(topic, false, false)
+
+		ts.persisted.Lock()
+		ts.persisted.topics[topic.ID] = usedAt
+		ts.persisted.Unlock()
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if ts.archive {
+		return nil
+	}
+
+	for _, id := range expired {
+		if err := ts.backend.DeleteTopic(id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// adjustInterval recalculates refresh interval of cached topic, after recording that it changed or was read.
+// Small differences, e.g. caused by jitter, are not worth rescheduling, unless topic changed.
+func (ts *TopicStore) adjustInterval(topic *Topic, changed, read bool) {
+	if ts.maxInterval == 0 {
+		return
+	}
+
+	now := time.Now()
+
+	ts.activity.Lock()
+	activity, ok := ts.activity.topics[topic.ID]
+	if !ok {
+		activity = &topicActivity{changedAt: now}
+		ts.activity.topics[topic.ID] = activity
+	}
+	if changed {
+		activity.changedAt = now
+	}
+	if read {
+		activity.read(now)
+	}
+
+	interval := refreshInterval(topic, activity, now, ts.minInterval, ts.maxInterval, ts.jitter)
+	update := !ok || changed || math.Abs(float64(interval-activity.interval)) > float64(activity.interval)/4
+	if update {
+		activity.interval = interval
+	}
+	ts.activity.Unlock()
+
+	if update {
+		ts.Cache.SetInterval(topic.ID, interval)
+	}
+}
+
+// indexTopic adds topic, that is already in the cache, to all indexes.
+func (ts *TopicStore) indexTopic(topic *Topic) {
 	ts.Lock()
-	defer ts.Unlock()
 	if !ts.indexed(topic.ID) {
 		ts.index = append(ts.index, topic.ID)
 	}
 
+	// topic could be moved to another forum in the meantime
+	if forumID, ok := ts.topicForum[topic.ID]; !ok || forumID != topic.ForumID {
+		if ok {
+			ts.forumIndex[forumID] = removeID(ts.forumIndex[forumID], topic.ID)
+		}
+		ts.forumIndex[topic.ForumID] = append(ts.forumIndex[topic.ForumID], topic.ID)
+		ts.topicForum[topic.ID] = topic.ForumID
+	}
+
 	// re-index anyway, value can be different
 	ts.ReIndex()
+	sort.Sort(&forumIndex{ids: ts.forumIndex[topic.ForumID], store: ts})
+	ts.Unlock()
+
+	for _, indexer := range ts.indexers {
+		indexer.IndexTopic(topic)
+	}
+}
+
+// remove drops topic, that is already gone from the cache, from all indexes.
+// Unless archive is enabled, it is removed from the backend as well.
+func (ts *TopicStore) remove(id int) {
+	ts.unindex(id)
+
+	if ts.backend != nil && !ts.archive {
+		if err := ts.backend.DeleteTopic(id); err != nil {
+			ts.err <- err
+		}
+	}
+}
+
+// unindex drops topic from in-memory indexes only. It is enough for evicted topic, that is still valid
+// and stays in the backend, so it can be restored after restart.
+func (ts *TopicStore) unindex(id int) {
+	ts.Lock()
+	ts.index = removeID(ts.index, id)
+	if forumID, ok := ts.topicForum[id]; ok {
+		ts.forumIndex[forumID] = removeID(ts.forumIndex[forumID], id)
+		delete(ts.topicForum, id)
+	}
+	ts.Unlock()
+
+	for _, indexer := range ts.indexers {
+		indexer.RemoveTopic(id)
+	}
+
+	ts.activity.Lock()
+	delete(ts.activity.topics, id)
+	ts.activity.Unlock()
 
-	return
+	ts.persisted.Lock()
+	delete(ts.persisted.topics, id)
+	ts.persisted.Unlock()
+
+	ts.missing.Lock()
+	delete(ts.missing.topics, id)
+	ts.missing.Unlock()
+
+	ts.refreshing.Lock()
+	delete(ts.refreshing.topics, id)
+	ts.refreshing.Unlock()
 }
 
 func (ts *TopicStore) indexed(id int) bool {
@@ -74,23 +516,78 @@ func (ts *TopicStore) listenCache() {
 				return
 			}
 
-			topic, err := ts.client.FetchTopic(id)
-			if err != nil {
-				ts.err <- err
-				continue
-			}
+			ts.refreshing.Lock()
+			ts.refreshing.topics[id] = struct{}{}
+			ts.refreshing.Unlock()
 
-			ts.Set(topic)
-			log.Printf("[%d] update notification - topic fetched and updated successfully: %s", topic.ID, topic.Title)
+			// refresh conditionally if previous version is still there, peek does not extend its expiration
+			if topic, ok := ts.Peek(id); ok {
+				ts.adjustInterval(topic, false, false)
+				ts.crawler.Refresh(topic)
+			} else {
+				ts.crawler.Enqueue(id)
+			}
 		case e, open := <-ts.Cache.Err():
 			if !open {
 				return
 			}
+			switch removed := e.(type) {
+			case *cache.ExpiredError[int]:
+				ts.remove(removed.Key)
+			case *cache.EvictedError[int]:
+				ts.unindex(removed.Key)
+				// archive kept in memory would grow past cache limits, evicted topic is not worth keeping there
+				if ts.memArchive {
+					if err := ts.backend.DeleteTopic(removed.Key); err != nil {
+						ts.err <- err
+					}
+				}
+			}
+			ts.err <- e
+		}
+	}
+}
+
+func (ts *TopicStore) listenCrawler() {
+	for {
+		select {
+		case topic := <-ts.crawler.Result():
+			ts.Set(topic)
+			ts.recordRefresh(topic.ID, nil)
+			log.Printf("[%d] crawler - topic fetched and updated successfully: %s", topic.ID, topic.Title)
+		case id := <-ts.crawler.Unchanged():
+			ts.markRefreshed(id)
+			ts.recordRefresh(id, nil)
+		case id := <-ts.crawler.Deleted():
+			if ts.confirmDeleted(id) {
+				ts.markDeleted(id)
+				log.Printf("[%d] crawler - topic deleted", id)
+			} else {
+				ts.recordRefresh(id, ErrTopicDeleted)
+				log.Printf("[%d] crawler - topic not found, waiting for the next refresh to confirm", id)
+			}
+		case e := <-ts.crawler.Err():
+			if te, ok := e.(*topicError); ok {
+				ts.recordRefresh(te.id, te.err)
+			}
 			ts.err <- e
 		}
 	}
 }
 
+// recordRefresh reports outcome of the refresh to the cache, if the cache asked for it.
+// Topics fetched during warm up or on first read are not refreshes, they are not counted.
+func (ts *TopicStore) recordRefresh(id int, err error) {
+	ts.refreshing.Lock()
+	_, ok := ts.refreshing.topics[id]
+	delete(ts.refreshing.topics, id)
+	ts.refreshing.Unlock()
+
+	if ok {
+		ts.Cache.RecordRefresh(id, err)
+	}
+}
+
 // ReIndex is not thread safe!
 func (ts *TopicStore) ReIndex() {
 	sort.Sort(ts)
@@ -108,12 +605,18 @@ func (ts *TopicStore) Swap(i, j int) {
 
 // Less is not thread safe!
 func (ts *TopicStore) Less(i, j int) bool {
-	ti, ok1 := ts.Get(ts.index[i]).(*Topic)
-	tj, ok2 := ts.Get(ts.index[j]).(*Topic)
+	return ts.less(ts.index[i], ts.index[j])
+}
+
+// less reports whether topic i was updated before topic j. Sorting is not an access, so expiration is not extended.
+// It is not thread safe!
+func (ts *TopicStore) less(i, j int) bool {
+	ti, ok1 := ts.Lookup(i)
+	tj, ok2 := ts.Lookup(j)
 
+	// topic could be already expired or evicted, while index is not updated yet
 	if !ok1 || !ok2 {
-		ts.err <- errors.New("cache contains object that cannot be casted to Topic")
-		return false
+		return !ok1 && ok2
 	}
 
 	return ti.UpdatedAt.Before(*tj.UpdatedAt)
@@ -124,57 +627,191 @@ func (ts *TopicStore) Err() <-chan error {
 	return ts.err
 }
 
-// List ...
+// List returns topics ordered from the most recently updated one.
 func (ts *TopicStore) List(offset, limit int) ([]*Topic, error) {
-	if limit == 0 {
-		return []*Topic{}, nil
-	}
+	ts.RLock()
+	defer ts.RUnlock()
+
+	return ts.list(ts.index, offset, limit, true)
+}
+
+// ListByForum works like List, but returns only topics that belong to given forum.
+func (ts *TopicStore) ListByForum(forumID, offset, limit int) ([]*Topic, error) {
+	ts.RLock()
+	defer ts.RUnlock()
+
+	return ts.list(ts.forumIndex[forumID], offset, limit, true)
+}
+
+// Latest returns limit of the most recently updated topics, like List does, but without extending their expiration.
+// It is meant for feeds, so readers polling them do not keep topics alive forever.
+func (ts *TopicStore) Latest(limit int) ([]*Topic, error) {
+	ts.RLock()
+	defer ts.RUnlock()
+
+	return ts.list(ts.index, 0, limit, false)
+}
 
+// LatestByForum works like Latest, but returns only topics that belong to given forum.
+func (ts *TopicStore) LatestByForum(forumID, limit int) ([]*Topic, error) {
 	ts.RLock()
 	defer ts.RUnlock()
 
-	if offset > len(ts.index) {
-		return nil, errors.New("offset out of range")
+	return ts.list(ts.forumIndex[forumID], 0, limit, false)
+}
+
+// TopicFilter narrows down list of topics. Zero value matches every topic.
+type TopicFilter struct {
+	ForumID      int
+	Author       string
+	LastPostBy   string
+	Sticky       *bool
+	Announcement *bool
+	Locked       *bool
+	MinReplies   int
+	MinViews     int
+	// From and To limit creation date of the topic.
+	From *time.Time
+	To   *time.Time
+}
+
+// Match reports whether topic satisfies all criteria of the filter.
+func (tf TopicFilter) Match(topic *Topic) bool {
+	switch {
+	case tf.ForumID != 0 && topic.ForumID != tf.ForumID:
+		return false
+	case tf.Author != "" && !strings.EqualFold(topic.CreatedBy, tf.Author):
+		return false
+	case tf.LastPostBy != "" && !strings.EqualFold(topic.LastPostBy, tf.LastPostBy):
+		return false
+	case tf.Sticky != nil && topic.Sticky != *tf.Sticky:
+		return false
+	case tf.Announcement != nil && topic.Announcement != *tf.Announcement:
+		return false
+	case tf.Locked != nil && topic.Locked != *tf.Locked:
+		return false
+	case topic.Replies < tf.MinReplies, topic.Views < tf.MinViews:
+		return false
+	case tf.From != nil && (topic.CreatedAt == nil || topic.CreatedAt.Before(*tf.From)):
+		return false
+	case tf.To != nil && (topic.CreatedAt == nil || topic.CreatedAt.After(*tf.To)):
+		return false
 	}
 
-	if limit > len(ts.index) {
-		limit = len(ts.index)
+	return true
+}
+
+// ListFiltered works like List, but returns only topics that match given filter.
+// Only returned topics have their expiration extended.
+func (ts *TopicStore) ListFiltered(filter TopicFilter, offset, limit int) ([]*Topic, error) {
+	if filter == (TopicFilter{}) {
+		return ts.List(offset, limit)
 	}
 
-	topics := make([]*Topic, 0, limit-offset)
+	ts.RLock()
+	index := make([]int, len(ts.index))
+	copy(index, ts.index)
+	ts.RUnlock()
 
-	for i := limit - 1; i >= offset; i-- {
-		topics = append(topics, ts.Get(ts.index[i]).(*Topic))
+	if offset > len(index) {
+		return nil, ErrOffsetOutOfRange
+	}
+
+	topics := make([]*Topic, 0)
+	for i := len(index) - 1; i >= 0 && len(topics) < limit; i-- {
+		topic, ok := ts.Peek(index[i])
+		if !ok || !filter.Match(topic) {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+
+		ts.SafeGet(topic.ID)
+		topics = append(topics, topic)
 	}
 
 	return topics, nil
 }
 
-func (ts *TopicStore) warmUp(warmUp int) {
-	topics := make(chan *Topic)
+// list is not thread safe! Index is expected to be sorted from the least recently updated topic.
+// Expiration of listed topics is extended only if read is set.
+func (ts *TopicStore) list(index []int, offset, limit int, read bool) ([]*Topic, error) {
+	if offset < 0 || limit < 0 {
+		return nil, errors.New("offset and limit cannot be negative")
+	}
 
-	go func() {
-		for topic := range topics {
-			ts.Set(topic)
-			log.Printf("[%d] warmup - topic fetched and updated successfully: %s", topic.ID, topic.Title)
+	if limit == 0 {
+		return []*Topic{}, nil
+	}
+
+	if offset > len(index) {
+		return nil, ErrOffsetOutOfRange
+	}
+
+	if offset+limit > len(index) {
+		limit = len(index) - offset
+	}
+
+	get := ts.Get
+	if !read {
+		get = ts.Lookup
+	}
+
+	topics := make([]*Topic, 0, limit)
+
+	for i := len(index) - offset - 1; i >= len(index)-offset-limit; i-- {
+		// topic could be already expired or evicted, while index is not updated yet
+		if topic, ok := get(index[i]); ok {
+			topics = append(topics, topic)
 		}
-	}()
+	}
 
-	if err := ts.client.FetchTopics(warmUp, topics); err != nil {
-		close(topics)
-		ts.err <- err
+	return topics, nil
+}
+
+func (ts *TopicStore) warmUp(warmUp int) {
+	ctx := context.Background()
 
+	forumIDs, err := ts.forums.IDs(ctx)
+	if err != nil {
+		ts.err <- err
 		return
 	}
+
+	ts.crawler.Crawl(ctx, forumIDs, warmUp)
 }
 
-// GetOrRetrieve ...
-func (ts *TopicStore) GetOrRetrieve(id int) (*Topic, error) {
+// GetOrRetrieve returns cached topic or fetches it. Fetching stops once ctx is done.
+// If archive is enabled, expired topic is served from it, marked as stale. Deleted topics are served as tombstones,
+// unless it is time to check them again and they turn out to be restored.
+// ErrTopicDeleted is returned only for topics that were never seen.
+func (ts *TopicStore) GetOrRetrieve(ctx context.Context, id int) (*Topic, error) {
 	var err error
 
-	topic, ok := ts.SafeGet(id).(*Topic)
+	topic, ok := ts.SafeGet(id)
+	if ok {
+		ts.adjustInterval(topic, false, true)
+		ts.touch(id)
+	} else {
+		var recheck bool
+		if topic, ok, recheck = ts.tombstone(id); recheck {
+			// failures other than deletion are not worth failing the request, tombstone is served instead
+			if restored, err := ts.client.FetchTopic(ctx, id); err == nil {
+				ts.Set(restored)
+				return restored, nil
+			}
+		}
+	}
+	if !ok && ts.archive {
+		if topic, err = ts.archived(id); err != nil {
+			return nil, err
+		}
+		ok = topic != nil
+	}
 	if !ok {
-		topic, err = ts.client.FetchTopic(id)
+		topic, err = ts.client.FetchTopic(ctx, id)
 		if err != nil {
 			return nil, err
 		}
@@ -184,3 +821,84 @@ func (ts *TopicStore) GetOrRetrieve(id int) (*Topic, error) {
 
 	return topic, nil
 }
+
+// touch persists the time topic was read, unless it was persisted recently.
+func (ts *TopicStore) touch(id int) {
+	if ts.backend == nil {
+		return
+	}
+
+	now := time.Now()
+
+	ts.persisted.Lock()
+	persistedAt, ok := ts.persisted.topics[id]
+	if !ok || now.Sub(persistedAt) < topicTouchInterval {
+		ts.persisted.Unlock()
+		return
+	}
+	ts.persisted.topics[id] = now
+	ts.persisted.Unlock()
+
+	if err := ts.backend.TouchTopic(id, now); err != nil {
+		ts.err <- err
+	}
+}
+
+// archived returns stale copy of the topic from the archive, or nil if it is not there.
+// Depending on revive policy, topic goes back to the cache and gets refreshed.
+func (ts *TopicStore) archived(id int) (*Topic, error) {
+	topic, _, err := ts.backend.GetTopic(id)
+	if err != nil || topic == nil {
+		return nil, err
+	}
+
+	// tombstone could have been forgotten, it is kept in memory again, so it gets checked
+	if topic.DeletedAt != nil {
+		ts.addTombstone(topic)
+	}
+
+	if ts.revive == ReviveOnRead && topic.DeletedAt == nil {
+		ts.Cache.Set(topic.ID, topic)
+		ts.indexTopic(topic)
+		ts.adjustInterval(topic, false, true)
+
+		// crawler queue can be full, e.g. during warm up, request does not wait for it
+		go ts.crawler.Refresh(topic)
+	}
+
+	stale := *topic
+	stale.Stale = true
+
+	return &stale, nil
+}
+
+// forumIndex orders topics of a single forum the same way ReIndex orders global index.
+type forumIndex struct {
+	ids   []int
+	store *TopicStore
+}
+
+// Len implements sort.Interface.
+func (fi *forumIndex) Len() int {
+	return len(fi.ids)
+}
+
+// Swap implements sort.Interface.
+func (fi *forumIndex) Swap(i, j int) {
+	fi.ids[i], fi.ids[j] = fi.ids[j], fi.ids[i]
+}
+
+// Less implements sort.Interface.
+func (fi *forumIndex) Less(i, j int) bool {
+	return fi.store.less(fi.ids[i], fi.ids[j])
+}
+
+func removeID(ids []int, id int) []int {
+	for i, indexed := range ids {
+		if indexed == id {
+			return append(ids[:i], ids[i+1:]...)
+		}
+	}
+
+	return ids
+}
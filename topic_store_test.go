@@ -0,0 +1,416 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/netwars/api/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/net/context"
+)
+
+func TestTopicStore_archive(t *testing.T) {
+	for policy, revived := range map[RevivePolicy]bool{ReviveNever: false, ReviveOnRead: true} {
+		now := time.Now()
+		client := &ClientMock{}
+		client.On("FetchTopic", 1).Return(&Topic{ID: 1, ForumID: 12, Title: "Zergi", UpdatedAt: &now}, nil).Once()
+		client.On("RefreshTopic", mock.Anything).Return(nil, ErrNotModified)
+
+		store, err := NewTopicStore(client, cache.NewCache[int](cache.CacheOpts[*Topic]{
+			Expiration: 50 * time.Millisecond,
+			Interval:   1000000 * time.Hour,
+		}), NewCrawler(client, CrawlerOpts{}), NewForumStore(client, ForumStoreOpts{}), TopicStoreOpts{
+			Archive: true,
+			Revive:  policy,
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		topic, err := store.GetOrRetrieve(context.Background(), 1)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.False(t, topic.Stale)
+		assert.NotNil(t, topic.LastRefreshedAt)
+
+		select {
+		case err := <-store.Err():
+			assert.IsType(t, &cache.ExpiredError[int]{}, err)
+		case <-time.After(time.Second):
+			assert.Fail(t, "topic should expire")
+			return
+		}
+
+		topics, err := store.List(0, 10)
+		if assert.NoError(t, err) {
+			assert.Len(t, topics, 0)
+		}
+
+		archived, err := store.GetOrRetrieve(context.Background(), 1)
+		if assert.NoError(t, err) {
+			assert.True(t, archived.Stale)
+			assert.Equal(t, "Zergi", archived.Title)
+			assert.Equal(t, topic.LastRefreshedAt, archived.LastRefreshedAt)
+		}
+
+		topics, err = store.List(0, 10)
+		if assert.NoError(t, err) {
+			assert.Len(t, topics, map[bool]int{false: 0, true: 1}[revived], "policy %d", policy)
+		}
+		client.AssertNumberOfCalls(t, "FetchTopic", 1)
+	}
+}
+
+func TestTopicStore_unchanged(t *testing.T) {
+	client := &ClientMock{}
+	client.On("RefreshTopic", mock.Anything).Return(nil, ErrNotModified)
+
+	store, err := NewTopicStore(client, cache.NewCache[int](cache.CacheOpts[*Topic]{
+		Expiration: 1000000 * time.Hour,
+		Interval:   20 * time.Millisecond,
+	}), NewCrawler(client, CrawlerOpts{}), NewForumStore(client, ForumStoreOpts{}), TopicStoreOpts{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	now := time.Now()
+	store.Set(&Topic{ID: 1, ForumID: 12, Title: "Zergi", UpdatedAt: &now})
+	topic, _ := store.Peek(1)
+	storedAt := *topic.LastRefreshedAt
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if topic, _ := store.Peek(1); topic.LastRefreshedAt.After(storedAt) {
+			assert.Equal(t, now, *topic.UpdatedAt)
+			assert.Eventually(t, func() bool {
+				return store.Stats().RefreshSuccesses > 0
+			}, time.Second, time.Millisecond)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Fail(t, "refresh of unchanged topic should be recorded")
+}
+
+func TestTopicStore_recordRefresh(t *testing.T) {
+	now := time.Now()
+	client := &ClientMock{}
+	client.On("RefreshTopic", mock.Anything).Return(nil, ErrNotModified)
+	client.On("FetchTopic", 2).Return(&Topic{ID: 2, ForumID: 12, Title: "Protosi", UpdatedAt: &now}, nil)
+
+	store, err := NewTopicStore(client, cache.NewCache[int](cache.CacheOpts[*Topic]{
+		Expiration: 1000000 * time.Hour,
+		Interval:   1000000 * time.Hour,
+	}), NewCrawler(client, CrawlerOpts{}), NewForumStore(client, ForumStoreOpts{}), TopicStoreOpts{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// neither first fetch nor refresh that cache did not ask for is counted
+	store.Set(&Topic{ID: 1, ForumID: 12, Title: "Zergi", UpdatedAt: &now})
+	topic, _ := store.Peek(1)
+	storedAt := *topic.LastRefreshedAt
+	store.crawler.Refresh(topic)
+	store.crawler.Enqueue(2)
+
+	assert.Eventually(t, func() bool {
+		topic, _ := store.Peek(1)
+		_, fetched := store.Peek(2)
+		return topic.LastRefreshedAt.After(storedAt) && fetched
+	}, time.Second, time.Millisecond)
+
+	stats := store.Stats()
+	assert.Equal(t, uint64(0), stats.RefreshSuccesses)
+	assert.Equal(t, uint64(0), stats.RefreshFailures)
+}
+
+func TestTopicStore_deletions(t *testing.T) {
+	now := time.Now()
+	client := &ClientMock{}
+	client.On("RefreshTopic", mock.Anything).Return(nil, ErrTopicDeleted)
+
+	deletions := NewDeletionLog(10)
+	broker := NewEventBroker(10)
+	store, err := NewTopicStore(client, cache.NewCache[int](cache.CacheOpts[*Topic]{
+		Expiration: 1000000 * time.Hour,
+		Interval:   1000000 * time.Hour,
+	}), NewCrawler(client, CrawlerOpts{}), NewForumStore(client, ForumStoreOpts{}), TopicStoreOpts{
+		Deletions: deletions,
+		Events:    broker,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	sub, _ := broker.Subscribe(0)
+
+	store.Set(&Topic{ID: 1, ForumID: 12, Title: "Zergi", UpdatedAt: &now, Posts: []*Post{
+		{Serial: 1, TopicID: 1, CreatedBy: "alice", Content: "Jak grać?"},
+		{Serial: 2, TopicID: 1, CreatedBy: "bob", Content: "Spam"},
+		{Serial: 3, TopicID: 1, CreatedBy: "carol", Content: "Nie wiem."},
+	}})
+	for i := 0; i < 2; i++ {
+		store.Set(&Topic{ID: 1, ForumID: 12, Title: "Zergi", UpdatedAt: &now, Posts: []*Post{
+			{Serial: 1, TopicID: 1, CreatedBy: "alice", Content: "Jak grać?"},
+			{Serial: 3, TopicID: 1, CreatedBy: "carol", Content: "Nie wiem."},
+		}})
+	}
+
+	topic, err := store.GetOrRetrieve(context.Background(), 1)
+	if !assert.NoError(t, err) || !assert.Len(t, topic.Posts, 3) {
+		return
+	}
+	assert.Nil(t, topic.Posts[0].DeletedAt)
+	assert.NotNil(t, topic.Posts[1].DeletedAt)
+	assert.Equal(t, "Spam", topic.Posts[1].Content)
+	assert.Nil(t, topic.Posts[2].DeletedAt)
+
+	// deletion is reported once, even though tombstone is carried over
+	list := deletions.List(0, time.Time{}, 0, 10)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, DeletionPost, list[0].Type)
+		assert.Equal(t, int64(2), list[0].Serial)
+		assert.Equal(t, "bob", list[0].Author)
+	}
+
+	// single report is not trusted, the second one confirms deletion
+	store.crawler.Refresh(topic)
+	assert.Eventually(t, func() bool {
+		store.missing.Lock()
+		defer store.missing.Unlock()
+
+		_, ok := store.missing.topics[1]
+		return ok
+	}, time.Second, time.Millisecond)
+	store.crawler.Refresh(topic)
+
+	for event := range sub.C {
+		if event.Type == EventTopicDeleted {
+			assert.Equal(t, 1, event.TopicID)
+			break
+		}
+		assert.Equal(t, EventPostDeleted, event.Type)
+	}
+
+	tombstone, err := store.GetOrRetrieve(context.Background(), 1)
+	if assert.NoError(t, err) {
+		assert.NotNil(t, tombstone.DeletedAt)
+		assert.Len(t, tombstone.Posts, 3)
+	}
+
+	topics, err := store.List(0, 10)
+	if assert.NoError(t, err) {
+		assert.Len(t, topics, 0)
+	}
+
+	list = deletions.List(12, now, 0, 10)
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, DeletionTopic, list[0].Type)
+		assert.Equal(t, "Zergi", list[0].Title)
+	}
+	client.AssertNotCalled(t, "FetchTopic", 1)
+
+	// once it is time to check tombstone again, restored topic is served
+	store.tombstones.Lock()
+	store.tombstones.topics[1].checkedAt = now.Add(-tombstoneRecheck)
+	store.tombstones.Unlock()
+	client.On("FetchTopic", 1).Return(&Topic{ID: 1, ForumID: 12, Title: "Zergi", UpdatedAt: &now}, nil).Once()
+
+	restored, err := store.GetOrRetrieve(context.Background(), 1)
+	if assert.NoError(t, err) {
+		assert.Nil(t, restored.DeletedAt)
+	}
+	_, ok, _ := store.tombstone(1)
+	assert.False(t, ok)
+}
+
+func TestTopicStore_ListFiltered(t *testing.T) {
+	client := &ClientMock{}
+	store, err := NewTopicStore(client, cache.NewCache[int](cache.CacheOpts[*Topic]{
+		Expiration: 1000000 * time.Hour,
+		Interval:   1000000 * time.Hour,
+	}), NewCrawler(client, CrawlerOpts{}), NewForumStore(client, ForumStoreOpts{}), TopicStoreOpts{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	listedAt := time.Now()
+	for i, topic := range []*Topic{
+		{ID: 1, ForumID: 12, CreatedBy: "alice", Replies: 10, Views: 100, Sticky: true, ListedAt: &listedAt},
+		{ID: 2, ForumID: 12, CreatedBy: "bob", Replies: 2, Views: 20},
+		{ID: 3, ForumID: 4, CreatedBy: "Alice", Replies: 5, Views: 5, Locked: true},
+	} {
+		updatedAt := listedAt.Add(time.Duration(i) * time.Minute)
+		topic.UpdatedAt = &updatedAt
+		topic.CreatedAt = &updatedAt
+		store.Set(topic)
+	}
+
+	// refreshed version keeps metadata from the listing
+	first, _ := store.Peek(1)
+	second, _ := store.Peek(2)
+	store.Set(&Topic{ID: 1, ForumID: 12, CreatedBy: "alice", Replies: 11, UpdatedAt: first.UpdatedAt})
+
+	yes, no := true, false
+	for name, c := range map[string]struct {
+		filter TopicFilter
+		ids    []int
+	}{
+		"none":       {filter: TopicFilter{}, ids: []int{3, 2, 1}},
+		"forum":      {filter: TopicFilter{ForumID: 12}, ids: []int{2, 1}},
+		"author":     {filter: TopicFilter{Author: "alice"}, ids: []int{3, 1}},
+		"sticky":     {filter: TopicFilter{Sticky: &yes}, ids: []int{1}},
+		"not locked": {filter: TopicFilter{Locked: &no}, ids: []int{2, 1}},
+		"replies":    {filter: TopicFilter{MinReplies: 5}, ids: []int{3, 1}},
+		"views":      {filter: TopicFilter{MinViews: 50}, ids: []int{1}},
+		"from":       {filter: TopicFilter{From: second.CreatedAt}, ids: []int{3, 2}},
+	} {
+		topics, err := store.ListFiltered(c.filter, 0, 10)
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+
+		ids := make([]int, 0, len(topics))
+		for _, topic := range topics {
+			ids = append(ids, topic.ID)
+		}
+		assert.Equal(t, c.ids, ids, name)
+	}
+
+	topics, err := store.ListFiltered(TopicFilter{ForumID: 12}, 1, 10)
+	if assert.NoError(t, err) && assert.Len(t, topics, 1) {
+		assert.Equal(t, 1, topics[0].ID)
+		assert.Equal(t, 100, topics[0].Views)
+		assert.Equal(t, 11, topics[0].Replies)
+	}
+}
+
+func TestTopicStore_eviction(t *testing.T) {
+	client := &ClientMock{}
+	backend := NewMemoryBackend()
+	store, err := NewTopicStore(client, cache.NewCache[int](cache.CacheOpts[*Topic]{
+		Expiration: 1000000 * time.Hour,
+		Interval:   1000000 * time.Hour,
+		MaxEntries: 1,
+	}), NewCrawler(client, CrawlerOpts{}), NewForumStore(client, ForumStoreOpts{}), TopicStoreOpts{
+		Backend: backend,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	now := time.Now()
+	store.Set(&Topic{ID: 1, ForumID: 12, UpdatedAt: &now})
+	store.Set(&Topic{ID: 2, ForumID: 12, UpdatedAt: &now})
+
+	select {
+	case err := <-store.Err():
+		assert.Equal(t, &cache.EvictedError[int]{Key: 1}, err)
+	case <-time.After(time.Second):
+		assert.Fail(t, "topic should be evicted")
+		return
+	}
+
+	topics, err := store.ListByForum(12, 0, 10)
+	if assert.NoError(t, err) && assert.Len(t, topics, 1) {
+		assert.Equal(t, 2, topics[0].ID)
+	}
+
+	// evicted topic is still valid, it stays in the backend
+	evicted, _, err := backend.GetTopic(1)
+	if assert.NoError(t, err) && assert.NotNil(t, evicted) {
+		assert.Equal(t, 1, evicted.ID)
+	}
+}
+
+func TestTopicStore_evictionMemoryArchive(t *testing.T) {
+	client := &ClientMock{}
+	store, err := NewTopicStore(client, cache.NewCache[int](cache.CacheOpts[*Topic]{
+		Expiration: 1000000 * time.Hour,
+		Interval:   1000000 * time.Hour,
+		MaxEntries: 1,
+	}), NewCrawler(client, CrawlerOpts{}), NewForumStore(client, ForumStoreOpts{}), TopicStoreOpts{
+		Archive: true,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	now := time.Now()
+	store.Set(&Topic{ID: 1, ForumID: 12, UpdatedAt: &now})
+	store.Set(&Topic{ID: 2, ForumID: 12, UpdatedAt: &now})
+
+	select {
+	case err := <-store.Err():
+		assert.Equal(t, &cache.EvictedError[int]{Key: 1}, err)
+	case <-time.After(time.Second):
+		assert.Fail(t, "topic should be evicted")
+		return
+	}
+
+	// archive kept in memory is bounded by the cache
+	evicted, _, err := store.backend.GetTopic(1)
+	if assert.NoError(t, err) {
+		assert.Nil(t, evicted)
+	}
+}
+
+func TestRefreshInterval(t *testing.T) {
+	now := time.Now()
+	posts := func(gap time.Duration, last time.Time) []*Post {
+		posts := make([]*Post, 0, 10)
+		for i := 9; i >= 0; i-- {
+			createdAt := last.Add(-time.Duration(i) * gap)
+			posts = append(posts, &Post{Serial: int64(10 - i), CreatedAt: &createdAt})
+		}
+		return posts
+	}
+	readOften := &topicActivity{changedAt: now}
+	for i := 0; i < 100; i++ {
+		readOften.read(now)
+	}
+
+	for name, c := range map[string]struct {
+		posts    []*Post
+		activity *topicActivity
+		expected time.Duration
+	}{
+		"hot":           {posts: posts(6*time.Second, now), activity: &topicActivity{changedAt: now}, expected: 5 * time.Second},
+		"busy":          {posts: posts(10*time.Minute, now), activity: &topicActivity{changedAt: now}, expected: 5 * time.Minute},
+		"busy and read": {posts: posts(10*time.Minute, now), activity: readOften, expected: 90 * time.Second},
+		"gone quiet":    {posts: posts(10*time.Second, now), activity: &topicActivity{changedAt: now.Add(-40 * time.Minute)}, expected: 20 * time.Minute},
+		"dead":          {posts: posts(time.Hour, now.AddDate(-1, 0, 0)), activity: &topicActivity{changedAt: now}, expected: time.Hour},
+		"future dates":  {posts: posts(10*time.Minute, now.Add(2*time.Hour)), activity: &topicActivity{changedAt: now}, expected: 5 * time.Minute},
+	} {
+		interval := refreshInterval(&Topic{Posts: c.posts}, c.activity, now, 5*time.Second, time.Hour, 0)
+		assert.InDelta(t, float64(c.expected), float64(interval), float64(c.expected)/10, name)
+	}
+
+	for i := 0; i < 100; i++ {
+		interval := refreshInterval(&Topic{Posts: posts(6*time.Second, now)}, &topicActivity{changedAt: now}, now, 5*time.Second, time.Hour, 0.5)
+		assert.True(t, interval >= 5*time.Second && interval <= 10*time.Second, "jittered interval has to stay within bounds")
+	}
+}
+
+func TestTopicStore_Latest(t *testing.T) {
+	client := &ClientMock{}
+	client.On("RefreshTopic", mock.Anything).Return(nil, ErrNotModified)
+
+	store, err := NewTopicStore(client, cache.NewCache[int](cache.CacheOpts[*Topic]{
+		Expiration: 50 * time.Millisecond,
+		Interval:   1000000 * time.Hour,
+	}), NewCrawler(client, CrawlerOpts{}), NewForumStore(client, ForumStoreOpts{}), TopicStoreOpts{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	now := time.Now()
+	store.Set(&Topic{ID: 1, ForumID: 12, Title: "Zergi", UpdatedAt: &now})
+
+	// polling feeds does not keep topic alive
+	assert.Eventually(t, func() bool {
+		topics, err := store.Latest(10)
+		return err == nil && len(topics) == 0
+	}, time.Second, 10*time.Millisecond)
+}
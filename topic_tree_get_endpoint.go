@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/piotrkowalczuk/rest"
+	"golang.org/x/net/context"
+)
+
+// TopicTreeGetResponse ...
+type TopicTreeGetResponse struct {
+	*Topic
+	Posts []*PostNode `json:"posts"`
+}
+
+// TopicTreeGetEndpoint returns topic with posts arranged into conversation tree.
+func TopicTreeGetEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(TopicGetRequest)
+	if !ok {
+		return nil, rest.InternalServerError(errBadCast, internalServerErrorMessage, 0)
+	}
+
+	storage, err := TopicStorageFromContext(ctx)
+	if err != nil {
+		return nil, rest.InternalServerError(err, internalServerErrorMessage, 0)
+	}
+
+	topic, err := storage.GetOrRetrieve(ctx, req.TopicID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TopicTreeGetResponse{
+		Topic: topic,
+		Posts: NewPostTree(topic.Posts),
+	}, nil
+}
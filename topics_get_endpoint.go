@@ -1,7 +1,6 @@
 package main
 
 import (
-	"github.com/go-kit/kit/endpoint"
 	"github.com/piotrkowalczuk/rest"
 	"golang.org/x/net/context"
 )
@@ -10,7 +9,7 @@ import (
 func TopicsGetEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
 	req, ok := request.(TopicsGetRequest)
 	if !ok {
-		return nil, rest.InternalServerError(endpoint.ErrBadCast, internalServerErrorMessage, 0)
+		return nil, rest.InternalServerError(errBadCast, internalServerErrorMessage, 0)
 	}
 
 	storage, err := TopicStorageFromContext(ctx)
@@ -18,19 +17,35 @@ func TopicsGetEndpoint(ctx context.Context, request interface{}) (interface{}, e
 		return nil, rest.InternalServerError(err, internalServerErrorMessage, 0)
 	}
 
-	topics, err := storage.List(req.Offset, req.Limit)
+	topics, err := storage.ListFiltered(req.Filter, req.Offset, req.Limit)
 	if err != nil {
 		return nil, err
 	}
 
+	return newTopicsGetResponse(topics), nil
+}
+
+// newTopicsGetResponse builds list of topics without their posts.
+func newTopicsGetResponse(topics []*Topic) []map[string]interface{} {
 	response := make([]map[string]interface{}, 0, len(topics))
 	for _, topic := range topics {
 		response = append(response, map[string]interface{}{
-			"id":        topic.ID,
-			"forumId":   topic.ForumID,
-			"title":     topic.Title,
-			"updatedAt": topic.UpdatedAt,
+			"id":           topic.ID,
+			"forumId":      topic.ForumID,
+			"title":        topic.Title,
+			"pages":        topic.Pages,
+			"createdAt":    topic.CreatedAt,
+			"createdBy":    topic.CreatedBy,
+			"createdById":  topic.CreatedByID,
+			"updatedAt":    topic.UpdatedAt,
+			"replies":      topic.Replies,
+			"views":        topic.Views,
+			"lastPostBy":   topic.LastPostBy,
+			"sticky":       topic.Sticky,
+			"announcement": topic.Announcement,
+			"locked":       topic.Locked,
 		})
 	}
-	return response, nil
+
+	return response
 }
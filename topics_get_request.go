@@ -4,29 +4,84 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/piotrkowalczuk/rest"
 	"golang.org/x/net/context"
 )
 
 // TopicsGetRequest ...
 type TopicsGetRequest struct {
+	Filter TopicFilter
 	Offset int
 	Limit  int
 }
 
 // TopicsGetRequestDecode ...
 func TopicsGetRequestDecode(ctx context.Context, r *http.Request) (interface{}, error) {
-	offset, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 32)
+	query := r.URL.Query()
+
+	offset, limit, err := parsePage(query)
+	if err != nil {
+		return nil, err
+	}
+
+	forumID, err := strconv.ParseInt(query.Get("forumId"), 10, 32)
 	if err != nil {
-		offset = 0
+		forumID = 0
 	}
 
-	limit, err := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 32)
+	minReplies, err := strconv.ParseInt(query.Get("minReplies"), 10, 32)
 	if err != nil {
-		limit = 10
+		minReplies = 0
+	}
+
+	minViews, err := strconv.ParseInt(query.Get("minViews"), 10, 32)
+	if err != nil {
+		minViews = 0
+	}
+
+	filter := TopicFilter{
+		ForumID:    int(forumID),
+		Author:     query.Get("author"),
+		LastPostBy: query.Get("lastPostBy"),
+		MinReplies: int(minReplies),
+		MinViews:   int(minViews),
+	}
+
+	for name, flag := range map[string]**bool{
+		"sticky":       &filter.Sticky,
+		"announcement": &filter.Announcement,
+		"locked":       &filter.Locked,
+	} {
+		if *flag, err = parseFlag(query.Get(name)); err != nil {
+			return nil, &rest.Error{Message: "malformed " + name + " flag", HTTPCode: http.StatusBadRequest}
+		}
+	}
+
+	if filter.From, err = parseSearchDate(query.Get("from"), false); err != nil {
+		return nil, &rest.Error{Message: "malformed from date", HTTPCode: http.StatusBadRequest}
+	}
+
+	if filter.To, err = parseSearchDate(query.Get("to"), true); err != nil {
+		return nil, &rest.Error{Message: "malformed to date", HTTPCode: http.StatusBadRequest}
 	}
 
 	return TopicsGetRequest{
-		Offset: int(offset),
-		Limit:  int(limit),
+		Filter: filter,
+		Offset: offset,
+		Limit:  limit,
 	}, nil
 }
+
+// parseFlag returns nil if flag is not given, so it is possible to tell it apart from false.
+func parseFlag(raw string) (*bool, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	flag, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return &flag, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	userNickSelector      = "div.profil .nick"
+	userAvatarSelector    = "div.profil img.avatar"
+	userRankSelector      = "div.profil .ranga"
+	userJoinedAtSelector  = "div.profil .data_rejestracji"
+	userPostCountSelector = "div.profil .liczba_postow"
+	userSignatureSelector = "div.profil .podpis"
+)
+
+// User ...
+type User struct {
+	ID        int        `json:"id"`
+	Nick      string     `json:"nick"`
+	AvatarURL string     `json:"avatarUrl"`
+	Rank      string     `json:"rank"`
+	JoinedAt  *time.Time `json:"joinedAt"`
+	PostCount int        `json:"postCount"`
+	Signature string     `json:"signature"`
+}
+
+// NewUserFromDocument parse given profile document to find matching patterns and returns User instance if it is possible.
+func NewUserFromDocument(doc *goquery.Document) (*User, error) {
+	userID, err := userIDFromHref(doc.Url.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	nick := strings.TrimSpace(doc.Find(userNickSelector).First().Text())
+	if nick == "" {
+		return nil, errors.New("missing nick in document")
+	}
+
+	user := &User{
+		ID:        userID,
+		Nick:      nick,
+		Rank:      strings.TrimSpace(doc.Find(userRankSelector).First().Text()),
+		Signature: strings.TrimSpace(doc.Find(userSignatureSelector).First().Text()),
+	}
+
+	if src, ok := doc.Find(userAvatarSelector).First().Attr("src"); ok {
+		if u, err := doc.Url.Parse(src); err == nil {
+			user.AvatarURL = u.String()
+		}
+	}
+
+	if joinedAt := strings.TrimSpace(doc.Find(userJoinedAtSelector).First().Text()); joinedAt != "" {
+		if user.JoinedAt, err = parseDate(joinedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	if user.PostCount, err = parseCount(doc.Find(userPostCountSelector).First().Text()); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// userIDFromHref extracts user id from profile links like /profil/<id>.
+func userIDFromHref(href string) (int, error) {
+	i := strings.Index(href, "/profil/")
+	if i < 0 {
+		return 0, errors.New("malformed profile url")
+	}
+
+	userID, err := strconv.ParseInt(strings.Trim(href[i+8:], "/"), 10, 32)
+	if err != nil {
+		return 0, errors.New("malformed user id in url")
+	}
+
+	return int(userID), nil
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"github.com/piotrkowalczuk/rest"
+	"golang.org/x/net/context"
+)
+
+// UserGetEndpoint ...
+func UserGetEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(UserGetRequest)
+	if !ok {
+		return nil, rest.InternalServerError(errBadCast, internalServerErrorMessage, 0)
+	}
+
+	storage, err := UserStorageFromContext(ctx)
+	if err != nil {
+		return nil, rest.InternalServerError(err, internalServerErrorMessage, 0)
+	}
+
+	return storage.GetOrRetrieve(ctx, req.UserID)
+}
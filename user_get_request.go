@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/piotrkowalczuk/rest"
+	"golang.org/x/net/context"
+)
+
+// UserGetRequest ...
+type UserGetRequest struct {
+	UserID int `json:"userId"`
+}
+
+// UserGetRequestDecode ...
+func UserGetRequestDecode(ctx context.Context, _ *http.Request) (interface{}, error) {
+	userID, err := rest.ParamFromContextInt(ctx, "userId")
+	if err != nil {
+		return nil, err
+	}
+
+	return UserGetRequest{
+		UserID: userID,
+	}, nil
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"github.com/piotrkowalczuk/rest"
+	"golang.org/x/net/context"
+)
+
+// UserPostsGetEndpoint returns posts of the user found in cached topics, newest first.
+func UserPostsGetEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(UserPostsGetRequest)
+	if !ok {
+		return nil, rest.InternalServerError(errBadCast, internalServerErrorMessage, 0)
+	}
+
+	storage, err := UserStorageFromContext(ctx)
+	if err != nil {
+		return nil, rest.InternalServerError(err, internalServerErrorMessage, 0)
+	}
+
+	return storage.Posts(req.UserID, req.Offset, req.Limit)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/piotrkowalczuk/rest"
+	"golang.org/x/net/context"
+)
+
+// UserPostsGetRequest ...
+type UserPostsGetRequest struct {
+	UserID int
+	Offset int
+	Limit  int
+}
+
+// UserPostsGetRequestDecode ...
+func UserPostsGetRequestDecode(ctx context.Context, r *http.Request) (interface{}, error) {
+	userID, err := rest.ParamFromContextInt(ctx, "userId")
+	if err != nil {
+		return nil, err
+	}
+
+	offset, limit, err := parsePage(r.URL.Query())
+	if err != nil {
+		return nil, err
+	}
+
+	return UserPostsGetRequest{
+		UserID: userID,
+		Offset: offset,
+		Limit:  limit,
+	}, nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/netwars/api/cache"
+	"golang.org/x/net/context"
+)
+
+// UserStore keeps user profiles and indexes posts of every cached topic by their author.
+type UserStore struct {
+	*cache.Cache[int, *User]
+	err    chan error
+	client Client
+	posts  struct {
+		sync.RWMutex
+		// byTopic holds posts of every indexed topic that have known author
+		byTopic map[int][]*Post
+		// byAuthor holds identifiers of topics in which given user posted
+		byAuthor map[int]map[int]struct{}
+	}
+}
+
+// NewUserStore ...
+func NewUserStore(client Client, cache *cache.Cache[int, *User]) *UserStore {
+	store := &UserStore{
+		Cache:  cache,
+		client: client,
+		err:    make(chan error, 1),
+	}
+	store.posts.byTopic = make(map[int][]*Post)
+	store.posts.byAuthor = make(map[int]map[int]struct{})
+
+	go store.listenCache()
+
+	return store
+}
+
+// GetOrRetrieve returns cached user or fetches its profile. Fetching stops once ctx is done.
+func (us *UserStore) GetOrRetrieve(ctx context.Context, id int) (*User, error) {
+	var err error
+
+	user, ok := us.SafeGet(id)
+	if !ok {
+		user, err = us.client.FetchUser(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		us.Set(user.ID, user)
+	}
+
+	return user, nil
+}
+
+// IndexTopic implements TopicIndexer.
+func (us *UserStore) IndexTopic(topic *Topic) {
+	posts := make([]*Post, 0, len(topic.Posts))
+	for _, post := range topic.Posts {
+		if post.CreatedByID != 0 {
+			posts = append(posts, post)
+		}
+	}
+
+	us.posts.Lock()
+	defer us.posts.Unlock()
+
+	us.removeTopic(topic.ID)
+
+	us.posts.byTopic[topic.ID] = posts
+	for _, post := range posts {
+		topics, ok := us.posts.byAuthor[post.CreatedByID]
+		if !ok {
+			topics = make(map[int]struct{})
+			us.posts.byAuthor[post.CreatedByID] = topics
+		}
+		topics[topic.ID] = struct{}{}
+	}
+}
+
+// RemoveTopic implements TopicIndexer.
+func (us *UserStore) RemoveTopic(id int) {
+	us.posts.Lock()
+	defer us.posts.Unlock()
+
+	us.removeTopic(id)
+}
+
+// removeTopic is not thread safe!
+func (us *UserStore) removeTopic(id int) {
+	for _, post := range us.posts.byTopic[id] {
+		if topics, ok := us.posts.byAuthor[post.CreatedByID]; ok {
+			delete(topics, id)
+			if len(topics) == 0 {
+				delete(us.posts.byAuthor, post.CreatedByID)
+			}
+		}
+	}
+
+	delete(us.posts.byTopic, id)
+}
+
+// Posts returns posts written by given user across all cached topics, newest first.
+func (us *UserStore) Posts(userID, offset, limit int) ([]*Post, error) {
+	us.posts.RLock()
+	posts := make(postsByCreatedAt, 0)
+	for topicID := range us.posts.byAuthor[userID] {
+		for _, post := range us.posts.byTopic[topicID] {
+			if post.CreatedByID == userID {
+				posts = append(posts, post)
+			}
+		}
+	}
+	us.posts.RUnlock()
+
+	if offset > len(posts) {
+		return nil, ErrOffsetOutOfRange
+	}
+
+	sort.Sort(sort.Reverse(posts))
+
+	if offset+limit > len(posts) {
+		limit = len(posts) - offset
+	}
+
+	return posts[offset : offset+limit], nil
+}
+
+// Err ...
+func (us *UserStore) Err() <-chan error {
+	return us.err
+}
+
+func (us *UserStore) listenCache() {
+	for {
+		select {
+		case id, open := <-us.Cache.Notify():
+			if !open {
+				return
+			}
+
+			user, err := us.client.FetchUser(context.Background(), id)
+			if err != nil {
+				us.err <- err
+				continue
+			}
+
+			us.Set(user.ID, user)
+		case e, open := <-us.Cache.Err():
+			if !open {
+				return
+			}
+			us.err <- e
+		}
+	}
+}
+
+type postsByCreatedAt []*Post
+
+// Len implements sort.Interface.
+func (p postsByCreatedAt) Len() int {
+	return len(p)
+}
+
+// Swap implements sort.Interface.
+func (p postsByCreatedAt) Swap(i, j int) {
+	p[i], p[j] = p[j], p[i]
+}
+
+// Less implements sort.Interface.
+func (p postsByCreatedAt) Less(i, j int) bool {
+	if p[i].CreatedAt.Equal(*p[j].CreatedAt) {
+		return p[i].Serial < p[j].Serial
+	}
+
+	return p[i].CreatedAt.Before(*p[j].CreatedAt)
+}
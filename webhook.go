@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// Webhook is HTTP target notified about new posts that match all of its non-empty filters.
+type Webhook struct {
+	ID       int      `json:"id"`
+	URL      string   `json:"url"`
+	Secret   string   `json:"secret,omitempty"`
+	ForumIDs []int    `json:"forumIds"`
+	TopicIDs []int    `json:"topicIds"`
+	Authors  []string `json:"authors"`
+	Keywords []string `json:"keywords"`
+	Enabled  bool     `json:"enabled"`
+	// Failures is a number of consecutive events that could not be delivered.
+	Failures   int        `json:"failures"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	DisabledAt *time.Time `json:"disabledAt,omitempty"`
+}
+
+// WebhookDelivery describes single attempt to deliver an event.
+type WebhookDelivery struct {
+	ID         uint64    `json:"id"`
+	WebhookID  int       `json:"webhookId"`
+	EventID    uint64    `json:"eventId"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Duration   float64   `json:"duration"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// matches reports whether event satisfies every filter of the webhook.
+func (w *Webhook) matches(e *Event) bool {
+	if e.Type != EventPostCreated || e.Post == nil {
+		return false
+	}
+	if len(w.ForumIDs) > 0 && !containsInt(w.ForumIDs, e.ForumID) {
+		return false
+	}
+	if len(w.TopicIDs) > 0 && !containsInt(w.TopicIDs, e.TopicID) {
+		return false
+	}
+	if len(w.Authors) > 0 {
+		found := false
+		for _, author := range w.Authors {
+			if strings.EqualFold(author, e.Post.CreatedBy) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(w.Keywords) > 0 {
+		content := strings.ToLower(e.Post.Content)
+		found := false
+		for _, keyword := range w.Keywords {
+			if strings.Contains(content, strings.ToLower(keyword)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// public returns copy of the webhook that can be exposed, without the secret.
+func (w *Webhook) public() *Webhook {
+	c := *w
+	c.Secret = ""
+
+	return &c
+}
+
+func containsInt(s []int, i int) bool {
+	for _, v := range s {
+		if v == i {
+			return true
+		}
+	}
+
+	return false
+}
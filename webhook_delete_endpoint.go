@@ -0,0 +1,25 @@
+package main
+
+import (
+	"github.com/piotrkowalczuk/rest"
+	"golang.org/x/net/context"
+)
+
+// WebhookDeleteEndpoint removes webhook together with its delivery log.
+func WebhookDeleteEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(WebhookGetRequest)
+	if !ok {
+		return nil, rest.InternalServerError(errBadCast, internalServerErrorMessage, 0)
+	}
+
+	dispatcher, err := WebhookDispatcherFromContext(ctx)
+	if err != nil {
+		return nil, rest.InternalServerError(err, internalServerErrorMessage, 0)
+	}
+
+	if err := dispatcher.Delete(req.WebhookID); err != nil {
+		return nil, webhookError(err)
+	}
+
+	return struct{}{}, nil
+}
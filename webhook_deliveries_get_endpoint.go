@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/piotrkowalczuk/rest"
+	"golang.org/x/net/context"
+)
+
+// WebhookDeliveriesGetEndpoint returns recent delivery attempts of the webhook, newest first.
+func WebhookDeliveriesGetEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(WebhookGetRequest)
+	if !ok {
+		return nil, rest.InternalServerError(errBadCast, internalServerErrorMessage, 0)
+	}
+
+	dispatcher, err := WebhookDispatcherFromContext(ctx)
+	if err != nil {
+		return nil, rest.InternalServerError(err, internalServerErrorMessage, 0)
+	}
+
+	deliveries, err := dispatcher.Deliveries(req.WebhookID)
+	if err != nil {
+		return nil, webhookError(err)
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// WebhookSignatureHeader carries hex encoded HMAC-SHA256 of the request body, keyed with webhook secret.
+	WebhookSignatureHeader = "X-Netwars-Signature"
+	// WebhookEventHeader carries type of the delivered event.
+	WebhookEventHeader = "X-Netwars-Event"
+	// WebhookDeliveryHeader carries identifier of the delivery, the same for every retry.
+	WebhookDeliveryHeader = "X-Netwars-Delivery"
+)
+
+var (
+	// ErrWebhookNotFound is returned if webhook with given id does not exist.
+	ErrWebhookNotFound = errors.New("webhook: not found")
+)
+
+// WebhookDispatcherOpts ...
+type WebhookDispatcherOpts struct {
+	// HTTPClient is used to deliver payloads, http.DefaultClient is used if nil.
+	HTTPClient *http.Client
+	// Retries is a number of additional attempts made after failed delivery.
+	Retries    int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// MaxFailures is a number of consecutive undelivered events after which webhook is disabled.
+	// Zero means webhooks are never disabled.
+	MaxFailures int
+	// LogSize is a number of recent delivery attempts kept per webhook.
+	LogSize int
+}
+
+// WebhookDispatcher keeps registered webhooks and delivers them new posts published by EventBroker.
+type WebhookDispatcher struct {
+	sync.RWMutex
+	http           *http.Client
+	retries        int
+	backoff        time.Duration
+	maxBackoff     time.Duration
+	maxFailures    int
+	logSize        int
+	lastID         int
+	lastDeliveryID uint64
+	webhooks       map[int]*Webhook
+	deliveries     map[int][]*WebhookDelivery
+	err            chan error
+}
+
+// NewWebhookDispatcher allocates new dispatcher and subscribes it to given broker.
+func NewWebhookDispatcher(broker *EventBroker, opts WebhookDispatcherOpts) *WebhookDispatcher {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+
+	wd := &WebhookDispatcher{
+		http:        opts.HTTPClient,
+		retries:     opts.Retries,
+		backoff:     opts.Backoff,
+		maxBackoff:  opts.MaxBackoff,
+		maxFailures: opts.MaxFailures,
+		logSize:     opts.LogSize,
+		webhooks:    make(map[int]*Webhook),
+		deliveries:  make(map[int][]*WebhookDelivery),
+		err:         make(chan error, 1),
+	}
+
+	// subscribe before returning, so no event published afterwards is missed
+	sub, _ := broker.Subscribe(0)
+	go wd.listen(broker, sub)
+
+	return wd
+}
+
+// Err ...
+func (wd *WebhookDispatcher) Err() <-chan error {
+	return wd.err
+}
+
+// Create registers new, enabled webhook. Random secret is generated if none is given.
+// Returned webhook is the only place where the secret is exposed.
+func (wd *WebhookDispatcher) Create(w *Webhook) (*Webhook, error) {
+	if w.Secret == "" {
+		secret := make([]byte, 20)
+		if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+			return nil, err
+		}
+		w.Secret = hex.EncodeToString(secret)
+	}
+
+	wd.Lock()
+	defer wd.Unlock()
+
+	wd.lastID++
+	w.ID = wd.lastID
+	w.Enabled = true
+	w.Failures = 0
+	w.CreatedAt = time.Now()
+	w.DisabledAt = nil
+	wd.webhooks[w.ID] = w
+
+	created := *w
+
+	return &created, nil
+}
+
+// Get ...
+func (wd *WebhookDispatcher) Get(id int) (*Webhook, error) {
+	wd.RLock()
+	defer wd.RUnlock()
+
+	w, ok := wd.webhooks[id]
+	if !ok {
+		return nil, ErrWebhookNotFound
+	}
+
+	return w.public(), nil
+}
+
+// List returns all webhooks ordered by id.
+func (wd *WebhookDispatcher) List() []*Webhook {
+	wd.RLock()
+	defer wd.RUnlock()
+
+	webhooks := make([]*Webhook, 0, len(wd.webhooks))
+	for _, w := range wd.webhooks {
+		webhooks = append(webhooks, w.public())
+	}
+	sort.Sort(webhooksByID(webhooks))
+
+	return webhooks
+}
+
+// Delete removes webhook together with its delivery log.
+func (wd *WebhookDispatcher) Delete(id int) error {
+	wd.Lock()
+	defer wd.Unlock()
+
+	if _, ok := wd.webhooks[id]; !ok {
+		return ErrWebhookNotFound
+	}
+
+	delete(wd.webhooks, id)
+	delete(wd.deliveries, id)
+
+	return nil
+}
+
+// Enable turns webhook back on and resets its failure counter.
+func (wd *WebhookDispatcher) Enable(id int) (*Webhook, error) {
+	wd.Lock()
+	defer wd.Unlock()
+
+	w, ok := wd.webhooks[id]
+	if !ok {
+		return nil, ErrWebhookNotFound
+	}
+
+	w.Enabled = true
+	w.Failures = 0
+	w.DisabledAt = nil
+
+	return w.public(), nil
+}
+
+// Deliveries returns recent delivery attempts of the webhook, newest first.
+func (wd *WebhookDispatcher) Deliveries(id int) ([]*WebhookDelivery, error) {
+	wd.RLock()
+	defer wd.RUnlock()
+
+	if _, ok := wd.webhooks[id]; !ok {
+		return nil, ErrWebhookNotFound
+	}
+
+	log := wd.deliveries[id]
+	deliveries := make([]*WebhookDelivery, 0, len(log))
+	for i := len(log) - 1; i >= 0; i-- {
+		deliveries = append(deliveries, log[i])
+	}
+
+	return deliveries, nil
+}
+
+// listen consumes events for as long as the process lives.
+// If subscription is dropped because dispatcher could not keep up, it resumes from the last seen event.
+func (wd *WebhookDispatcher) listen(broker *EventBroker, sub *EventSubscription) {
+	var lastEventID uint64
+
+	for {
+		for e := range sub.C {
+			lastEventID = e.ID
+			wd.dispatch(e)
+		}
+
+		var backlog []*Event
+		sub, backlog = broker.Subscribe(lastEventID)
+		for _, e := range backlog {
+			lastEventID = e.ID
+			wd.dispatch(e)
+		}
+	}
+}
+
+func (wd *WebhookDispatcher) dispatch(e *Event) {
+	wd.Lock()
+	defer wd.Unlock()
+
+	for _, w := range wd.webhooks {
+		if !w.Enabled || !w.matches(e) {
+			continue
+		}
+
+		wd.lastDeliveryID++
+		go wd.deliver(*w, e, wd.lastDeliveryID)
+	}
+}
+
+// deliver sends the event to the webhook, retrying with exponential backoff.
+// Webhook is given by value, so the delivery is not affected by concurrent modifications.
+func (wd *WebhookDispatcher) deliver(w Webhook, e *Event, deliveryID uint64) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		wd.err <- err
+		return
+	}
+
+	backoff := wd.backoff
+
+	for attempt := 0; ; attempt++ {
+		started := time.Now()
+		code, err := wd.post(&w, e, deliveryID, body)
+
+		delivery := &WebhookDelivery{
+			ID:         deliveryID,
+			WebhookID:  w.ID,
+			EventID:    e.ID,
+			Attempt:    attempt + 1,
+			StatusCode: code,
+			Duration:   time.Since(started).Seconds(),
+			CreatedAt:  started,
+		}
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+
+		if !wd.record(delivery, err == nil, attempt >= wd.retries) || err == nil || attempt >= wd.retries {
+			return
+		}
+
+		<-time.After(backoff)
+
+		backoff *= 2
+		if wd.maxBackoff > 0 && backoff > wd.maxBackoff {
+			backoff = wd.maxBackoff
+		}
+	}
+}
+
+func (wd *WebhookDispatcher) post(w *Webhook, e *Event, deliveryID uint64, body []byte) (int, error) {
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", clientDefaultUserAgent)
+	req.Header.Set(WebhookEventHeader, e.Type)
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatUint(deliveryID, 10))
+	req.Header.Set(WebhookSignatureHeader, "sha256="+WebhookSignature(w.Secret, body))
+
+	resp, err := wd.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// record appends delivery to the log and updates failure counter once the outcome is final.
+// It returns false if webhook was removed or disabled in the meantime, so no more attempts should be made.
+func (wd *WebhookDispatcher) record(delivery *WebhookDelivery, ok, last bool) bool {
+	wd.Lock()
+	defer wd.Unlock()
+
+	w, exists := wd.webhooks[delivery.WebhookID]
+	if !exists {
+		return false
+	}
+
+	log := append(wd.deliveries[w.ID], delivery)
+	if wd.logSize > 0 && len(log) > wd.logSize {
+		log = log[len(log)-wd.logSize:]
+	}
+	wd.deliveries[w.ID] = log
+
+	switch {
+	case ok:
+		w.Failures = 0
+	case last:
+		w.Failures++
+		if wd.maxFailures > 0 && w.Failures >= wd.maxFailures && w.Enabled {
+			now := time.Now()
+			w.Enabled = false
+			w.DisabledAt = &now
+
+			select {
+			case wd.err <- fmt.Errorf("webhook %d disabled after %d failed deliveries", w.ID, w.Failures):
+			default:
+			}
+		}
+	}
+
+	return w.Enabled
+}
+
+// WebhookSignature returns hex encoded HMAC-SHA256 of the body, so receivers can verify origin of the payload.
+func WebhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type webhooksByID []*Webhook
+
+// Len implements sort.Interface.
+func (w webhooksByID) Len() int {
+	return len(w)
+}
+
+// Swap implements sort.Interface.
+func (w webhooksByID) Swap(i, j int) {
+	w[i], w[j] = w[j], w[i]
+}
+
+// Less implements sort.Interface.
+func (w webhooksByID) Less(i, j int) bool {
+	return w[i].ID < w[j].ID
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookDispatcher(t *testing.T) {
+	received := make(chan *http.Request, 10)
+	bodies := make(chan []byte, 10)
+	target := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer target.Close()
+
+	broker := NewEventBroker(10)
+	dispatcher := NewWebhookDispatcher(broker, WebhookDispatcherOpts{})
+
+	webhook, err := dispatcher.Create(&Webhook{URL: target.URL, Secret: "s3cr3t", ForumIDs: []int{12}, Keywords: []string{"ZERG"}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "s3cr3t", webhook.Secret)
+	assert.True(t, webhook.Enabled)
+
+	broker.Publish(
+		&Event{Type: EventPostEdited, TopicID: 1, ForumID: 12, Post: &Post{Serial: 1, Content: "zergi"}},
+		&Event{Type: EventPostCreated, TopicID: 1, ForumID: 13, Post: &Post{Serial: 2, Content: "zergi"}},
+		&Event{Type: EventPostCreated, TopicID: 1, ForumID: 12, Post: &Post{Serial: 3, Content: "protosi"}},
+		&Event{Type: EventPostCreated, TopicID: 1, ForumID: 12, Post: &Post{Serial: 4, Content: "Zergi górą"}},
+	)
+
+	select {
+	case r := <-received:
+		body := <-bodies
+
+		var event Event
+		if assert.NoError(t, json.Unmarshal(body, &event)) {
+			assert.Equal(t, int64(4), event.Post.Serial)
+		}
+		assert.Equal(t, "sha256="+WebhookSignature("s3cr3t", body), r.Header.Get(WebhookSignatureHeader))
+		assert.Equal(t, EventPostCreated, r.Header.Get(WebhookEventHeader))
+		assert.NotEmpty(t, r.Header.Get(WebhookDeliveryHeader))
+	case <-time.After(time.Second):
+		assert.Fail(t, "matching event should be delivered")
+		return
+	}
+
+	select {
+	case <-received:
+		assert.Fail(t, "only matching events should be delivered")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	deliveries, err := dispatcher.Deliveries(webhook.ID)
+	if assert.NoError(t, err) && assert.Len(t, deliveries, 1) {
+		assert.Equal(t, http.StatusOK, deliveries[0].StatusCode)
+		assert.Empty(t, deliveries[0].Error)
+	}
+
+	list := dispatcher.List()
+	if assert.Len(t, list, 1) {
+		assert.Empty(t, list[0].Secret)
+	}
+}
+
+func TestWebhookDispatcher_disable(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer target.Close()
+
+	broker := NewEventBroker(10)
+	dispatcher := NewWebhookDispatcher(broker, WebhookDispatcherOpts{
+		Retries:     2,
+		Backoff:     time.Millisecond,
+		MaxFailures: 2,
+	})
+
+	webhook, err := dispatcher.Create(&Webhook{URL: target.URL})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for serial := int64(1); serial <= 2; serial++ {
+		broker.Publish(&Event{Type: EventPostCreated, TopicID: 1, Post: &Post{Serial: serial}})
+
+		select {
+		case err := <-dispatcher.Err():
+			assert.Equal(t, int64(2), serial, "unexpected error: %v", err)
+		case <-time.After(200 * time.Millisecond):
+			assert.Equal(t, int64(1), serial, "webhook should be disabled")
+		}
+	}
+
+	webhook, err = dispatcher.Get(webhook.ID)
+	if assert.NoError(t, err) {
+		assert.False(t, webhook.Enabled)
+		assert.Equal(t, 2, webhook.Failures)
+		assert.NotNil(t, webhook.DisabledAt)
+	}
+
+	deliveries, err := dispatcher.Deliveries(webhook.ID)
+	if assert.NoError(t, err) && assert.Len(t, deliveries, 6) {
+		assert.Equal(t, 3, deliveries[0].Attempt)
+		assert.Equal(t, http.StatusServiceUnavailable, deliveries[0].StatusCode)
+		assert.NotEmpty(t, deliveries[0].Error)
+	}
+
+	webhook, err = dispatcher.Enable(webhook.ID)
+	if assert.NoError(t, err) {
+		assert.True(t, webhook.Enabled)
+		assert.Zero(t, webhook.Failures)
+	}
+
+	assert.NoError(t, dispatcher.Delete(webhook.ID))
+	_, err = dispatcher.Get(webhook.ID)
+	assert.Equal(t, ErrWebhookNotFound, err)
+}
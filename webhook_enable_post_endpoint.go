@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/piotrkowalczuk/rest"
+	"golang.org/x/net/context"
+)
+
+// WebhookEnablePostEndpoint turns back on webhook that was disabled after failed deliveries.
+func WebhookEnablePostEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(WebhookGetRequest)
+	if !ok {
+		return nil, rest.InternalServerError(errBadCast, internalServerErrorMessage, 0)
+	}
+
+	dispatcher, err := WebhookDispatcherFromContext(ctx)
+	if err != nil {
+		return nil, rest.InternalServerError(err, internalServerErrorMessage, 0)
+	}
+
+	webhook, err := dispatcher.Enable(req.WebhookID)
+	if err != nil {
+		return nil, webhookError(err)
+	}
+
+	return webhook, nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/piotrkowalczuk/rest"
+	"golang.org/x/net/context"
+)
+
+// WebhookGetEndpoint ...
+func WebhookGetEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(WebhookGetRequest)
+	if !ok {
+		return nil, rest.InternalServerError(errBadCast, internalServerErrorMessage, 0)
+	}
+
+	dispatcher, err := WebhookDispatcherFromContext(ctx)
+	if err != nil {
+		return nil, rest.InternalServerError(err, internalServerErrorMessage, 0)
+	}
+
+	webhook, err := dispatcher.Get(req.WebhookID)
+	if err != nil {
+		return nil, webhookError(err)
+	}
+
+	return webhook, nil
+}
+
+// webhookError maps dispatcher errors to HTTP errors.
+func webhookError(err error) error {
+	if err == ErrWebhookNotFound {
+		return &rest.Error{Message: "webhook not found", HTTPCode: http.StatusNotFound}
+	}
+
+	return rest.InternalServerError(err, internalServerErrorMessage, 0)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/piotrkowalczuk/rest"
+	"golang.org/x/net/context"
+)
+
+// WebhookGetRequest ...
+type WebhookGetRequest struct {
+	WebhookID int `json:"webhookId"`
+}
+
+// WebhookGetRequestDecode ...
+func WebhookGetRequestDecode(ctx context.Context, _ *http.Request) (interface{}, error) {
+	webhookID, err := rest.ParamFromContextInt(ctx, "webhookId")
+	if err != nil {
+		return nil, err
+	}
+
+	return WebhookGetRequest{
+		WebhookID: webhookID,
+	}, nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"github.com/piotrkowalczuk/rest"
+	"golang.org/x/net/context"
+)
+
+// WebhookPostEndpoint registers new webhook. Response is the only place where its secret is returned.
+func WebhookPostEndpoint(ctx context.Context, request interface{}) (interface{}, error) {
+	req, ok := request.(WebhookPostRequest)
+	if !ok {
+		return nil, rest.InternalServerError(errBadCast, internalServerErrorMessage, 0)
+	}
+
+	dispatcher, err := WebhookDispatcherFromContext(ctx)
+	if err != nil {
+		return nil, rest.InternalServerError(err, internalServerErrorMessage, 0)
+	}
+
+	webhook, err := dispatcher.Create(&Webhook{
+		URL:      req.URL,
+		Secret:   req.Secret,
+		ForumIDs: req.ForumIDs,
+		TopicIDs: req.TopicIDs,
+		Authors:  req.Authors,
+		Keywords: req.Keywords,
+	})
+	if err != nil {
+		return nil, rest.InternalServerError(err, internalServerErrorMessage, 0)
+	}
+
+	return webhook, nil
+}
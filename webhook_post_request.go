@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/piotrkowalczuk/rest"
+	"golang.org/x/net/context"
+)
+
+// WebhookPostRequest ...
+type WebhookPostRequest struct {
+	URL      string   `json:"url"`
+	Secret   string   `json:"secret"`
+	ForumIDs []int    `json:"forumIds"`
+	TopicIDs []int    `json:"topicIds"`
+	Authors  []string `json:"authors"`
+	Keywords []string `json:"keywords"`
+}
+
+// WebhookPostRequestDecode ...
+func WebhookPostRequestDecode(_ context.Context, r *http.Request) (interface{}, error) {
+	var req WebhookPostRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, &rest.Error{Message: "malformed request body", HTTPCode: http.StatusBadRequest}
+	}
+
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, &rest.Error{Message: "url has to be absolute http or https address", HTTPCode: http.StatusBadRequest}
+	}
+
+	return req, nil
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"github.com/piotrkowalczuk/rest"
+	"golang.org/x/net/context"
+)
+
+// WebhooksGetEndpoint returns all registered webhooks, without their secrets.
+func WebhooksGetEndpoint(ctx context.Context, _ interface{}) (interface{}, error) {
+	dispatcher, err := WebhookDispatcherFromContext(ctx)
+	if err != nil {
+		return nil, rest.InternalServerError(err, internalServerErrorMessage, 0)
+	}
+
+	return dispatcher.List(), nil
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/net/context"
+	"golang.org/x/net/websocket"
+)
+
+// Actions accepted from WebSocket clients.
+const (
+	WebSocketActionSubscribe   = "subscribe"
+	WebSocketActionUnsubscribe = "unsubscribe"
+)
+
+// Types of messages sent to WebSocket clients.
+const (
+	WebSocketMessageSubscribed   = "subscribed"
+	WebSocketMessageUnsubscribed = "unsubscribed"
+	WebSocketMessageEvent        = "event"
+	WebSocketMessageError        = "error"
+)
+
+var (
+	errWebSocketUnknownAction = errors.New("unknown action")
+	errWebSocketMissingTarget = errors.New("one of topicId, forumId or author is required")
+	errWebSocketOrigin        = errors.New("origin not allowed")
+	errWebSocketNotRefreshed  = errors.New("topic is not refreshed anymore, it cannot be followed")
+)
+
+// WebSocketRequest subscribes to or unsubscribes from exactly one of: topic, forum or author.
+type WebSocketRequest struct {
+	Action  string `json:"action"`
+	TopicID int    `json:"topicId,omitempty"`
+	ForumID int    `json:"forumId,omitempty"`
+	Author  string `json:"author,omitempty"`
+}
+
+// WebSocketMessage ...
+type WebSocketMessage struct {
+	Type    string            `json:"type"`
+	Request *WebSocketRequest `json:"request,omitempty"`
+	Event   *Event            `json:"event,omitempty"`
+	Message string            `json:"message,omitempty"`
+}
+
+// WebSocketHandler lets clients subscribe to topics, forums and authors over a single connection
+// and pushes them events that match any of their subscriptions.
+// Topics that somebody is subscribed to are retrieved if needed and kept alive in the cache.
+// Browsers are accepted only from given origins, or from any if there are none. Clients that send no Origin header,
+// like chat bots, are always accepted, as they are not exposed to cross-site requests.
+func WebSocketHandler(ctx context.Context, origins []string) httprouter.Handle {
+	handshake := webSocketHandshake(origins)
+
+	return func(rw http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		storage, err := TopicStorageFromContext(ctx)
+		if err != nil {
+			log.Println(err)
+			http.Error(rw, internalServerErrorMessage, http.StatusInternalServerError)
+			return
+		}
+
+		broker, err := EventBrokerFromContext(ctx)
+		if err != nil {
+			log.Println(err)
+			http.Error(rw, internalServerErrorMessage, http.StatusInternalServerError)
+			return
+		}
+
+		server := websocket.Server{Handshake: handshake, Handler: func(ws *websocket.Conn) {
+			conn := &webSocketConn{
+				Conn:    ws,
+				storage: storage,
+				topics:  make(map[int]bool),
+				forums:  make(map[int]bool),
+				authors: make(map[string]bool),
+			}
+			defer conn.unpinAll()
+
+			sub, _ := broker.Subscribe(0)
+			defer broker.Unsubscribe(sub)
+
+			// request context is not cancelled when hijacked connection is closed, so it is done once listen returns
+			reqCtx, cancel := context.WithCancel(r.Context())
+			defer cancel()
+
+			go conn.push(sub)
+			conn.listen(reqCtx)
+		}}
+		server.ServeHTTP(rw, r)
+	}
+}
+
+// webSocketHandshake checks Origin header against allowed origins, e.g. https://example.com.
+func webSocketHandshake(origins []string) func(*websocket.Config, *http.Request) error {
+	return func(config *websocket.Config, r *http.Request) (err error) {
+		if config.Origin, err = websocket.Origin(config, r); err != nil || config.Origin == nil || len(origins) == 0 {
+			return err
+		}
+
+		for _, origin := range origins {
+			if strings.EqualFold(strings.TrimSuffix(origin, "/"), config.Origin.Scheme+"://"+config.Origin.Host) {
+				return nil
+			}
+		}
+
+		return errWebSocketOrigin
+	}
+}
+
+type webSocketConn struct {
+	*websocket.Conn
+	storage *TopicStore
+	write   sync.Mutex
+	mu      sync.RWMutex
+	topics  map[int]bool
+	forums  map[int]bool
+	authors map[string]bool
+}
+
+func (wc *webSocketConn) send(msg *WebSocketMessage) error {
+	wc.write.Lock()
+	defer wc.write.Unlock()
+
+	return websocket.JSON.Send(wc.Conn, msg)
+}
+
+// listen handles client requests until connection is closed.
+func (wc *webSocketConn) listen(ctx context.Context) {
+	for {
+		var req WebSocketRequest
+		if err := websocket.JSON.Receive(wc.Conn, &req); err != nil {
+			return
+		}
+
+		if err := wc.handle(ctx, &req); err != nil {
+			if err := wc.send(&WebSocketMessage{Type: WebSocketMessageError, Request: &req, Message: err.Error()}); err != nil {
+				return
+			}
+			continue
+		}
+
+		typ := WebSocketMessageSubscribed
+		if req.Action == WebSocketActionUnsubscribe {
+			typ = WebSocketMessageUnsubscribed
+		}
+		if err := wc.send(&WebSocketMessage{Type: typ, Request: &req}); err != nil {
+			return
+		}
+	}
+}
+
+func (wc *webSocketConn) handle(ctx context.Context, req *WebSocketRequest) error {
+	subscribe := req.Action == WebSocketActionSubscribe
+	if !subscribe && req.Action != WebSocketActionUnsubscribe {
+		return errWebSocketUnknownAction
+	}
+
+	switch {
+	case req.TopicID != 0:
+		wc.mu.Lock()
+		subscribed := wc.topics[req.TopicID]
+		wc.mu.Unlock()
+
+		switch {
+		case subscribe && !subscribed:
+			if _, err := wc.storage.GetOrRetrieve(ctx, req.TopicID); err != nil {
+				return err
+			}
+			// archived and deleted topics are served, but they are not cached, so they are not refreshed either.
+			// Topic is looked up once pinned, so it cannot expire in between.
+			wc.storage.Pin(req.TopicID)
+			if _, ok := wc.storage.Peek(req.TopicID); !ok {
+				wc.storage.Unpin(req.TopicID)
+				return errWebSocketNotRefreshed
+			}
+		case !subscribe && subscribed:
+			wc.storage.Unpin(req.TopicID)
+		}
+
+		wc.mu.Lock()
+		wc.topics[req.TopicID] = subscribe
+		if !subscribe {
+			delete(wc.topics, req.TopicID)
+		}
+		wc.mu.Unlock()
+	case req.ForumID != 0:
+		wc.mu.Lock()
+		wc.forums[req.ForumID] = subscribe
+		if !subscribe {
+			delete(wc.forums, req.ForumID)
+		}
+		wc.mu.Unlock()
+	case req.Author != "":
+		author := strings.ToLower(req.Author)
+
+		wc.mu.Lock()
+		wc.authors[author] = subscribe
+		if !subscribe {
+			delete(wc.authors, author)
+		}
+		wc.mu.Unlock()
+	default:
+		return errWebSocketMissingTarget
+	}
+
+	return nil
+}
+
+// push sends matching events until subscription is closed or connection fails.
+func (wc *webSocketConn) push(sub *EventSubscription) {
+	for e := range sub.C {
+		if !wc.matches(e) {
+			continue
+		}
+
+		if err := wc.send(&WebSocketMessage{Type: WebSocketMessageEvent, Event: e}); err != nil {
+			wc.Close()
+			return
+		}
+	}
+
+	// subscriber could not keep up, client has to reconnect
+	wc.Close()
+}
+
+func (wc *webSocketConn) matches(e *Event) bool {
+	wc.mu.RLock()
+	defer wc.mu.RUnlock()
+
+	if wc.topics[e.TopicID] || wc.forums[e.ForumID] {
+		return true
+	}
+
+	return e.Post != nil && wc.authors[strings.ToLower(e.Post.CreatedBy)]
+}
+
+func (wc *webSocketConn) unpinAll() {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+
+	for id := range wc.topics {
+		wc.storage.Unpin(id)
+	}
+	wc.topics = make(map[int]bool)
+}